@@ -0,0 +1,70 @@
+package mpic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one line of the audit log
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Device  string    `json:"device"`  /* USB serial, vid:pid if unknown */
+	Version int       `json:"version"` /* 10*ver + rls, 0 if not negotiated */
+	Op      string    `json:"op"`
+	Size    int       `json:"size"`
+	SHA256  string    `json:"sha256"` /* hash of the payload */
+	Error   string    `json:"error,omitempty"`
+}
+
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// WithAuditLog option records every state-changing operation (OUT commands
+// on EP1) as one JSON line appended to w. Writes are serialized, w is
+// never truncated or seeked.
+func WithAuditLog(w io.Writer) Option {
+	return func(u *Device) {
+		u.auditlog = &auditLog{w: w}
+	}
+}
+
+// audit appends one entry for op with payload to the audit log if enabled.
+// A failing audit write is returned so mutating operations do not go
+// unrecorded silently.
+func (u *Device) audit(op string, payload []byte, opErr error) error {
+	if u.auditlog == nil {
+		return nil
+	}
+	sum := sha256.Sum256(payload)
+	dev := u.serial
+	if dev == "" {
+		dev = fmt.Sprintf("%04x:%04x", mp42Vid, mp42Pid)
+	}
+	e := AuditEntry{
+		Time:    time.Now().UTC(),
+		Device:  dev,
+		Version: u.verl,
+		Op:      op,
+		Size:    len(payload),
+		SHA256:  hex.EncodeToString(sum[:]),
+	}
+	if opErr != nil {
+		e.Error = opErr.Error()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	u.auditlog.mu.Lock()
+	defer u.auditlog.mu.Unlock()
+	_, err = u.auditlog.w.Write(line)
+	return err
+}
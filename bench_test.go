@@ -0,0 +1,84 @@
+package mpic
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+// benchDevice returns a Device on the simulator, or on the attached
+// hardware when MPIC_BENCH_HW=1 is set
+func benchDevice(b *testing.B) *Device {
+	b.Helper()
+	if os.Getenv("MPIC_BENCH_HW") == "1" {
+		u, err := Open()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := u.ClaimInterface(0); err != nil {
+			u.Close()
+			b.Fatal(err)
+		}
+		b.Cleanup(u.Close)
+		return u
+	}
+	u, err := OpenTransport(NewSimulator(2, 1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(u.Close)
+	return u
+}
+
+func BenchmarkGetVersion(b *testing.B) {
+	u := benchDevice(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := u.GetVersion(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCommand(b *testing.B) {
+	u := benchDevice(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := u.Command(4, 0x93, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCommandInto(b *testing.B) {
+	u := benchDevice(b)
+	dst := make([]byte, maxPacketSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := u.CommandInto(dst, 4, 0x93, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNegotiate(b *testing.B) {
+	u := benchDevice(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := u.Negotiate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkResetBuffer(b *testing.B) {
+	for _, size := range []int{maxPacketSize, maxEcdLbuf14, maxUsbDsize} {
+		buf := make([]byte, maxUsbDsize)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				resetBuffer(buf, size)
+			}
+		})
+	}
+}
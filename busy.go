@@ -0,0 +1,83 @@
+package mpic
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// ErrDeviceBusy is matched (errors.Is) by ClaimInterface errors caused by
+// another process holding the device
+var ErrDeviceBusy = errors.New("Device busy")
+
+// DeviceBusyError is returned by ClaimInterface when the interface stayed
+// claimed by another process until the retry deadline
+type DeviceBusyError struct {
+	Interface uint32
+	PID       int    /* owning process, 0 if not found */
+	Process   string /* owning process name, if found */
+	Err       error  /* last ClaimInterface error */
+}
+
+func (e *DeviceBusyError) Error() string {
+	if e.PID != 0 {
+		return fmt.Sprintf("Device busy: interface %d held by %s (pid %d): %v", e.Interface, e.Process, e.PID, e.Err)
+	}
+	return fmt.Sprintf("Device busy: interface %d: %v", e.Interface, e.Err)
+}
+
+// Is function makes errors.Is(err, ErrDeviceBusy) true
+func (e *DeviceBusyError) Is(target error) bool { return target == ErrDeviceBusy }
+
+// Unwrap function returns the last ClaimInterface error
+func (e *DeviceBusyError) Unwrap() error { return e.Err }
+
+// WithClaimRetry option makes ClaimInterface retry with backoff (50ms
+// doubling up to 1s) while the device is busy, until deadline passed
+func WithClaimRetry(deadline time.Duration) Option {
+	return func(u *Device) {
+		u.claimWait = deadline
+	}
+}
+
+const libusbErrorBusy = -6 /* LIBUSB_ERROR_BUSY */
+
+// isBusy tells whether a claim error means another process holds the
+// interface: the usbfs EBUSY errno, or LIBUSB_ERROR_BUSY reported by an
+// error with a Code() int method, anywhere in the chain
+func isBusy(err error) bool {
+	if errors.Is(err, syscall.EBUSY) {
+		return true
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if c, ok := err.(interface{ Code() int }); ok && c.Code() == libusbErrorBusy {
+			return true
+		}
+	}
+	return false
+}
+
+// claimRetry claims interface n, retrying busy failures until the deadline
+func (u *Device) claimRetry(n uint32) error {
+	if u.dev == nil {
+		return ErrDeviceGone
+	}
+	end := time.Now().Add(u.claimWait)
+	wait := 50 * time.Millisecond
+	for {
+		err := u.dev.ClaimInterface(n)
+		if err == nil || !isBusy(err) {
+			return err
+		}
+		if time.Now().Add(wait).After(end) {
+			be := &DeviceBusyError{Interface: n, Err: err}
+			be.PID, be.Process = deviceOwner()
+			return be
+		}
+		time.Sleep(wait)
+		if wait *= 2; wait > time.Second {
+			wait = time.Second
+		}
+	}
+}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package mpic
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// deviceOwner returns the first other process with an mpic usbfs node open
+func deviceOwner() (int, string) {
+	devs, err := List()
+	if err != nil || len(devs) == 0 {
+		return 0, ""
+	}
+	nodes := make(map[string]bool)
+	for _, d := range devs {
+		nodes[d.devNode()] = true
+	}
+	procs, _ := os.ReadDir("/proc")
+	self := os.Getpid()
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		fdDir := filepath.Join("/proc", p.Name(), "fd")
+		fds, err := os.ReadDir(fdDir) /* fails for other users' processes */
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if link, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && nodes[link] {
+				comm, _ := os.ReadFile(filepath.Join("/proc", p.Name(), "comm"))
+				return pid, strings.TrimSpace(string(comm))
+			}
+		}
+	}
+	return 0, ""
+}
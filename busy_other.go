@@ -0,0 +1,7 @@
+//go:build !linux
+
+package mpic
+
+func deviceOwner() (int, string) {
+	return 0, ""
+}
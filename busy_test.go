@@ -0,0 +1,68 @@
+package mpic
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+)
+
+type codeError int
+
+func (e codeError) Error() string { return fmt.Sprintf("libusb error %d", int(e)) }
+func (e codeError) Code() int     { return int(e) }
+
+func TestIsBusy(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"errno", syscall.EBUSY, true},
+		{"wrapped errno", fmt.Errorf("claim interface 0: %w", syscall.EBUSY), true},
+		{"code", codeError(libusbErrorBusy), true},
+		{"wrapped code", fmt.Errorf("claim: %w", codeError(libusbErrorBusy)), true},
+		{"other code", codeError(-4), false},
+		{"other errno", syscall.ENODEV, false},
+		{"plain", errors.New("Resource busy"), false},
+		{"nil", nil, false},
+	} {
+		if got := isBusy(tc.err); got != tc.want {
+			t.Errorf("%s: isBusy = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+/* busyTransport fails every claim with err */
+type busyTransport struct {
+	*Simulator
+	err    error
+	claims int
+}
+
+func (b *busyTransport) ClaimInterface(n uint32) error {
+	b.claims++
+	return b.err
+}
+
+func TestClaimRetry(t *testing.T) {
+	bt := &busyTransport{Simulator: NewSimulator(2, 1), err: fmt.Errorf("claim: %w", syscall.EBUSY)}
+	u, err := OpenTransport(bt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	err = u.ClaimInterface(0)
+	var be *DeviceBusyError
+	if !errors.As(err, &be) || !errors.Is(err, ErrDeviceBusy) {
+		t.Fatalf("ClaimInterface: %v, want *DeviceBusyError", err)
+	}
+	if be.Interface != 0 || !errors.Is(be, syscall.EBUSY) || bt.claims != 1 {
+		t.Errorf("busy error %+v after %d claims", be, bt.claims)
+	}
+
+	bt.err, bt.claims = syscall.ENODEV, 0
+	if err := u.ClaimInterface(0); !errors.Is(err, syscall.ENODEV) || errors.Is(err, ErrDeviceBusy) || bt.claims != 1 {
+		t.Errorf("ClaimInterface: %v after %d claims, want ENODEV once", err, bt.claims)
+	}
+}
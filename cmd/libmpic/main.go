@@ -0,0 +1,195 @@
+// Command libmpic builds the mpic package as a C shared library for
+// C/C++/LabVIEW station software:
+//
+//	go build -buildmode=c-shared -o libmpic.so ./cmd/libmpic
+//
+// which also writes libmpic.h. All functions return 0 (or a byte count)
+// on success and a negative value on failure; mpic_last_error copies the
+// message of the last failure on a handle (handle 0 for mpic_open) into a
+// caller buffer. Handles are not safe for use from several threads at
+// once, mpic_open is.
+//
+// Encode/decode and EHT functions are not exported since the mpic package
+// does not implement them yet.
+package main
+
+/*
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/richardnwinder/mpic"
+)
+
+const (
+	errFailed = -1 /* operation failed, see mpic_last_error */
+	errHandle = -2 /* unknown handle */
+	errArg    = -3 /* bad argument */
+)
+
+type handle struct {
+	dev     *mpic.Device
+	lastErr string /* guarded by mu, handle 0 is shared by mpic_open */
+}
+
+var (
+	mu      sync.Mutex
+	handles = map[C.int]*handle{0: {}} /* 0 holds mpic_open errors */
+	next    C.int
+)
+
+// lookup returns the handle h, nil if unknown. Handle 0 has no device.
+func lookup(h C.int) *handle {
+	mu.Lock()
+	defer mu.Unlock()
+	return handles[h]
+}
+
+// fail stores err as the last error of hd and returns errFailed
+func (hd *handle) fail(err error) C.int {
+	mu.Lock()
+	hd.lastErr = err.Error()
+	mu.Unlock()
+	return errFailed
+}
+
+//export mpic_open
+func mpic_open() C.int {
+	dev, err := mpic.Open()
+	if err != nil {
+		return lookup(0).fail(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	next++
+	handles[next] = &handle{dev: dev}
+	return next
+}
+
+//export mpic_close
+func mpic_close(h C.int) {
+	if h == 0 {
+		return
+	}
+	mu.Lock()
+	hd := handles[h]
+	delete(handles, h)
+	mu.Unlock()
+	if hd == nil {
+		return
+	}
+	hd.dev.Close()
+}
+
+// mpic_last_error copies the last error message of h, NUL terminated and
+// truncated to buflen bytes, to buf. It returns the full message length,
+// like snprintf, so a result >= buflen means the message was truncated.
+//
+//export mpic_last_error
+func mpic_last_error(h C.int, buf *C.char, buflen C.int) C.int {
+	if buflen < 0 || (buflen > 0 && buf == nil) {
+		return errArg
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	hd := handles[h]
+	if hd == nil {
+		return errHandle
+	}
+	if buflen > 0 {
+		b := unsafe.Slice((*byte)(unsafe.Pointer(buf)), buflen)
+		b[copy(b[:buflen-1], hd.lastErr)] = 0
+	}
+	return C.int(len(hd.lastErr))
+}
+
+//export mpic_claim_interface
+func mpic_claim_interface(h C.int, n C.uint32_t) C.int {
+	hd := lookup(h)
+	if hd == nil || hd.dev == nil {
+		return errHandle
+	}
+	if err := hd.dev.ClaimInterface(uint32(n)); err != nil {
+		return hd.fail(err)
+	}
+	return 0
+}
+
+//export mpic_release_interface
+func mpic_release_interface(h C.int, n C.uint32_t) C.int {
+	hd := lookup(h)
+	if hd == nil || hd.dev == nil {
+		return errHandle
+	}
+	if err := hd.dev.ReleaseInterface(uint32(n)); err != nil {
+		return hd.fail(err)
+	}
+	return 0
+}
+
+//export mpic_version
+func mpic_version(h C.int, ver *C.int, rls *C.int) C.int {
+	hd := lookup(h)
+	if hd == nil || hd.dev == nil {
+		return errHandle
+	}
+	if ver == nil || rls == nil {
+		return errArg
+	}
+	iver, irls, err := hd.dev.GetVersion()
+	if err != nil {
+		return hd.fail(err)
+	}
+	*ver = C.int(iver)
+	*rls = C.int(irls)
+	return 0
+}
+
+//export mpic_negotiate
+func mpic_negotiate(h C.int) C.int {
+	hd := lookup(h)
+	if hd == nil || hd.dev == nil {
+		return errHandle
+	}
+	if err := hd.dev.Negotiate(); err != nil {
+		return hd.fail(err)
+	}
+	return 0
+}
+
+// mpic_command sends cmd with len bytes of data to dest and copies the IN
+// data (at most outlen bytes, 64 are enough) to out, returning its size.
+// A longer response fails, see mpic_last_error.
+//
+//export mpic_command
+func mpic_command(h C.int, dest C.uint8_t, cmd C.uint8_t, data *C.uint8_t, dlen C.int, out *C.uint8_t, outlen C.int) C.int {
+	hd := lookup(h)
+	if hd == nil || hd.dev == nil {
+		return errHandle
+	}
+	if dlen < 0 || outlen < 0 || (dlen > 0 && data == nil) || (outlen > 0 && out == nil) {
+		return errArg
+	}
+	var in []byte
+	if dlen > 0 {
+		in = C.GoBytes(unsafe.Pointer(data), dlen)
+	}
+	resp, err := hd.dev.Command(byte(dest), byte(cmd), in)
+	if err != nil {
+		return hd.fail(err)
+	}
+	if len(resp) > int(outlen) {
+		return hd.fail(fmt.Errorf("response of %d bytes exceeds outlen %d", len(resp), outlen))
+	}
+	if len(resp) > 0 {
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(out)), outlen), resp)
+	}
+	return C.int(len(resp))
+}
+
+func main() {}
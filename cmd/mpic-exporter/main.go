@@ -0,0 +1,192 @@
+// Command mpic-exporter scrapes mpic devices and serves their health,
+// version and command counters on /metrics in the Prometheus text format.
+//
+// Usage:
+//
+//	mpic-exporter [-addr :9342] [-local=true] [-remote host:port,...] [-sim]
+//
+// The local device is opened over USB; further devices are reached
+// through servers started with remote.Serve.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richardnwinder/mpic"
+	"github.com/richardnwinder/mpic/remote"
+	"github.com/richardnwinder/mpic/transport"
+)
+
+type target struct {
+	mu   sync.Mutex
+	name string
+	dev  *mpic.Device
+}
+
+func main() {
+	addr := flag.String("addr", ":9342", "listen address")
+	local := flag.Bool("local", true, "scrape the locally attached device")
+	remotes := flag.String("remote", "", "comma separated remote.Serve addresses")
+	sim := flag.Bool("sim", false, "scrape the built-in simulator as the local device")
+	secret := flag.String("secret", os.Getenv("MPIC_REMOTE_SECRET"), "remote.Serve shared secret")
+	flag.Parse()
+
+	var targets []*target
+	if *local {
+		var dev *mpic.Device
+		var err error
+		if *sim {
+			dev, err = mpic.OpenTransport(transport.NewSimulator(2, 1))
+		} else {
+			dev, err = mpic.Open()
+			if err == nil {
+				err = dev.ClaimInterface(0)
+			}
+		}
+		if err != nil {
+			log.Fatalf("local: %v", err)
+		}
+		targets = append(targets, &target{name: "local", dev: dev})
+	}
+	for _, a := range strings.Split(*remotes, ",") {
+		if a = strings.TrimSpace(a); a == "" {
+			continue
+		}
+		t, err := remote.Dial(a, remote.WithSecret(*secret))
+		if err != nil {
+			log.Fatalf("%s: %v", a, err)
+		}
+		dev, err := mpic.OpenTransport(t)
+		if err != nil {
+			log.Fatalf("%s: %v", a, err)
+		}
+		targets = append(targets, &target{name: a, dev: dev})
+	}
+	if len(targets) == 0 {
+		log.Fatal("no device to scrape")
+	}
+	for _, t := range targets {
+		if err := t.dev.Negotiate(); err != nil {
+			log.Printf("%s: negotiate: %v", t.name, err)
+		}
+	}
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		bw := bufio.NewWriter(w)
+		writeMetrics(r.Context(), bw, targets)
+		bw.Flush()
+	})
+	log.Printf("mpic-exporter listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func writeMetrics(ctx context.Context, w *bufio.Writer, targets []*target) {
+	type sample struct {
+		t   *target
+		rep *mpic.HealthReport
+		st  mpic.Stats
+		dur time.Duration
+	}
+	samples := make([]sample, len(targets))
+	for i, t := range targets {
+		t.mu.Lock()
+		start := time.Now()
+		rep, _ := t.dev.HealthCheck(ctx)
+		samples[i] = sample{t: t, rep: rep, st: t.dev.Stats(), dur: time.Since(start)}
+		t.mu.Unlock()
+	}
+
+	help(w, "mpic_up", "gauge", "1 if the device passed its health check")
+	for _, s := range samples {
+		fmt.Fprintf(w, "mpic_up{device=%q} %d\n", s.t.name, b2i(s.rep != nil && s.rep.Healthy))
+	}
+	help(w, "mpic_scrape_duration_seconds", "gauge", "time spent checking the device")
+	for _, s := range samples {
+		fmt.Fprintf(w, "mpic_scrape_duration_seconds{device=%q} %g\n", s.t.name, s.dur.Seconds())
+	}
+	help(w, "mpic_version_info", "gauge", "firmware version reported by the device")
+	for _, s := range samples {
+		if s.rep != nil && s.rep.Version != 0 {
+			fmt.Fprintf(w, "mpic_version_info{device=%q,version=\"%d.%d\"} 1\n", s.t.name, s.rep.Version, s.rep.Release)
+		}
+	}
+	help(w, "mpic_health_check_ok", "gauge", "1 if the health check step passed")
+	for _, s := range samples {
+		if s.rep == nil {
+			continue
+		}
+		for _, c := range s.rep.Checks {
+			if !c.Skipped {
+				fmt.Fprintf(w, "mpic_health_check_ok{device=%q,check=%q} %d\n", s.t.name, c.Name, b2i(c.OK))
+			}
+		}
+	}
+	help(w, "mpic_errors_total", "counter", "command errors by class")
+	for _, s := range samples {
+		for _, class := range sortedKeys(s.st.Errors) {
+			fmt.Fprintf(w, "mpic_errors_total{device=%q,class=%q} %d\n", s.t.name, class, s.st.Errors[class])
+		}
+	}
+	help(w, "mpic_commands_total", "counter", "commands issued by opcode")
+	for _, s := range samples {
+		for _, op := range sortedOpcodes(s.st) {
+			o := s.st.Opcodes[byte(op)]
+			fmt.Fprintf(w, "mpic_commands_total{device=%q,opcode=\"0x%02x\"} %d\n", s.t.name, op, o.Count+o.Errors)
+		}
+	}
+	help(w, "mpic_command_latency_seconds", "histogram", "command round-trip time by opcode")
+	for _, s := range samples {
+		for _, op := range sortedOpcodes(s.st) {
+			o := s.st.Opcodes[byte(op)]
+			lbl := fmt.Sprintf("device=%q,opcode=\"0x%02x\"", s.t.name, op)
+			cum := 0
+			for i, b := range o.Bounds {
+				cum += o.Buckets[i]
+				fmt.Fprintf(w, "mpic_command_latency_seconds_bucket{%s,le=\"%g\"} %d\n", lbl, b.Seconds(), cum)
+			}
+			fmt.Fprintf(w, "mpic_command_latency_seconds_bucket{%s,le=\"+Inf\"} %d\n", lbl, o.Count)
+			fmt.Fprintf(w, "mpic_command_latency_seconds_sum{%s} %g\n", lbl, o.Sum.Seconds())
+			fmt.Fprintf(w, "mpic_command_latency_seconds_count{%s} %d\n", lbl, o.Count)
+		}
+	}
+}
+
+func help(w *bufio.Writer, name string, typ string, text string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, text, name, typ)
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedOpcodes(st mpic.Stats) []int {
+	ops := make([]int, 0, len(st.Opcodes))
+	for op := range st.Opcodes {
+		ops = append(ops, int(op))
+	}
+	sort.Ints(ops)
+	return ops
+}
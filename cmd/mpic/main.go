@@ -0,0 +1,297 @@
+// Command mpic gives operators access to mpic devices without writing Go.
+//
+// Usage:
+//
+//	mpic [-config file] [-sim] <command> [flags]
+//
+// Commands:
+//
+//	list       list attached devices
+//	preflight  check device access before opening it
+//	label      print or set (-set name) the label of a device by -serial
+//	version    print firmware version and release
+//	info       negotiate and print version dependant capabilities
+//	health     run the health check and print the report
+//	power      print power and connection state
+//	diag       write a diagnostics archive (-o file, default stdout)
+//	commands   list the firmware commands of the connected device
+//	watch      print hotplug events until interrupted
+//	udev-rule  print the udev rule granting device access
+//
+// There are no encode, decode or EHT commands: package mpic does not
+// implement those operations yet.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/richardnwinder/mpic"
+	"github.com/richardnwinder/mpic/config"
+)
+
+var (
+	sim      = flag.Bool("sim", false, "use the built-in simulator instead of hardware")
+	confPath = flag.String("config", "", "config file (see package config)")
+	conf     *config.Config
+)
+
+type command struct {
+	name string
+	help string
+	run  func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"list", "list attached devices", cmdList},
+		{"preflight", "check device access before opening it", cmdPreflight},
+		{"label", "print or set the label of a device", cmdLabel},
+		{"version", "print firmware version and release", cmdVersion},
+		{"info", "negotiate and print version dependant capabilities", cmdInfo},
+		{"health", "run the health check and print the report", cmdHealth},
+		{"power", "print power and connection state", cmdPower},
+		{"diag", "write a diagnostics archive", cmdDiag},
+		{"commands", "list the firmware commands of the connected device", cmdCommands},
+		{"watch", "print hotplug events until interrupted", cmdWatch},
+		{"udev-rule", "print the udev rule granting device access", cmdUdevRule},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: mpic [-config file] [-sim] <command> [flags]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-10s %s\n", c.name, c.help)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(flag.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "mpic %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "mpic: unknown command %q\n", name)
+	usage()
+	os.Exit(2)
+}
+
+// openDevice opens and claims the device selected by -config (or the
+// simulator with -sim)
+func openDevice() (*mpic.Device, error) {
+	conf = config.Default()
+	if *confPath != "" {
+		c, err := config.Load(*confPath)
+		if err != nil {
+			return nil, err
+		}
+		conf = c
+	}
+	if *sim {
+		conf.Simulator = true
+	}
+	return conf.Open()
+}
+
+func closeDevice(dev *mpic.Device) {
+	dev.ReleaseInterface(uint32(conf.Interface))
+	dev.Close()
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func cmdList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	fs.Parse(args)
+	devs, err := mpic.List()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(devs)
+	}
+	for _, d := range devs {
+		fmt.Printf("%-10s bus %03d addr %03d  serial %q  label %q  %s\n", d.Path, d.Bus, d.Address, d.Serial, d.Label, d.Product)
+	}
+	return nil
+}
+
+func cmdLabel(args []string) error {
+	fs := flag.NewFlagSet("label", flag.ExitOnError)
+	serial := fs.String("serial", "", "device serial (default: the only attached device)")
+	set := fs.String("set", "", "new label")
+	del := fs.Bool("clear", false, "remove the label")
+	fs.Parse(args)
+	if *serial == "" {
+		devs, err := mpic.List()
+		if err != nil {
+			return err
+		}
+		if len(devs) != 1 {
+			return fmt.Errorf("%d devices attached, use -serial", len(devs))
+		}
+		*serial = devs[0].Serial
+	}
+	if *set != "" || *del {
+		return mpic.SetLabelOf(*serial, *set)
+	}
+	label, err := mpic.LabelOf(*serial)
+	if err != nil {
+		return err
+	}
+	fmt.Println(label)
+	return nil
+}
+
+func cmdPreflight(args []string) error {
+	err := mpic.Preflight()
+	if pe, ok := err.(*mpic.PreflightError); ok {
+		for _, is := range pe.Issues {
+			fmt.Println(is)
+		}
+		return fmt.Errorf("%d issue(s) found", len(pe.Issues))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func cmdVersion(args []string) error {
+	dev, err := openDevice()
+	if err != nil {
+		return err
+	}
+	defer closeDevice(dev)
+	iver, irls, err := dev.GetVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d.%d\n", iver, irls)
+	return nil
+}
+
+func cmdInfo(args []string) error {
+	dev, err := openDevice()
+	if err != nil {
+		return err
+	}
+	defer closeDevice(dev)
+	if err := dev.Negotiate(); err != nil {
+		return err
+	}
+	return printJSON(dev.Capabilities())
+}
+
+func cmdCommands(args []string) error {
+	dev, err := openDevice()
+	if err != nil {
+		return err
+	}
+	defer closeDevice(dev)
+	if err := dev.Negotiate(); err != nil {
+		return err
+	}
+	return printJSON(dev.ListCommands())
+}
+
+func cmdPower(args []string) error {
+	dev, err := openDevice()
+	if err != nil {
+		return err
+	}
+	defer closeDevice(dev)
+	ps, err := dev.PowerState()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mpic:", err)
+	}
+	return printJSON(ps)
+}
+
+func cmdHealth(args []string) error {
+	dev, err := openDevice()
+	if err != nil {
+		return err
+	}
+	defer closeDevice(dev)
+	rep, err := dev.HealthCheck(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := printJSON(rep); err != nil {
+		return err
+	}
+	if !rep.Healthy {
+		return fmt.Errorf("device unhealthy")
+	}
+	return nil
+}
+
+func cmdDiag(args []string) error {
+	fs := flag.NewFlagSet("diag", flag.ExitOnError)
+	out := fs.String("o", "", "output file (default stdout)")
+	fs.Parse(args)
+	dev, err := openDevice()
+	if err != nil {
+		return err
+	}
+	defer closeDevice(dev)
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return dev.Diagnostics(w)
+}
+
+func cmdWatch(args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	events, err := mpic.Watch(ctx)
+	if err != nil {
+		return err
+	}
+	for ev := range events {
+		fmt.Printf("%-8s %-10s bus %03d addr %03d  serial %q\n", ev.Type, ev.Device.Path, ev.Device.Bus, ev.Device.Address, ev.Device.Serial)
+	}
+	return nil
+}
+
+func cmdUdevRule(args []string) error {
+	fs := flag.NewFlagSet("udev-rule", flag.ExitOnError)
+	mode := fs.String("mode", "0660", "device node mode")
+	group := fs.String("group", "plugdev", "device node group")
+	fs.Parse(args)
+	fmt.Print(mpic.UdevRule(*mode, *group))
+	return nil
+}
@@ -0,0 +1,46 @@
+// Command mpicd serves an attached mpic device over HTTP, see package
+// mpichttp for the endpoints.
+//
+// Usage:
+//
+//	mpicd [-addr :8080] [-config file] [-sim]
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/richardnwinder/mpic/config"
+	"github.com/richardnwinder/mpic/mpichttp"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	sim := flag.Bool("sim", false, "use the built-in simulator instead of hardware")
+	confPath := flag.String("config", "", "config file (see package config)")
+	flag.Parse()
+
+	conf := config.Default()
+	if *confPath != "" {
+		c, err := config.Load(*confPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		conf = c
+	}
+	if *sim {
+		conf.Simulator = true
+	}
+	dev, err := conf.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dev.Close()
+	if err := dev.Negotiate(); err != nil {
+		log.Printf("negotiate: %v (using v1.2 defaults)", err)
+	}
+
+	log.Printf("mpicd listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mpichttp.NewHandler(dev)))
+}
@@ -0,0 +1,47 @@
+package mpic
+
+import (
+	"io"
+
+	"github.com/richardnwinder/mpic/protocol"
+)
+
+const maxCmdData = protocol.MaxCmdData
+
+// ErrCommandData is returned when command data exceeds 60 bytes
+var ErrCommandData = protocol.ErrCommandData
+
+// Command function sends cmd with data to dest (4 - mp4x) on EP1 and
+// returns a copy of the IN data for IN commands (cmd b7 = 1). Use
+// CommandInto to avoid the allocation in tight loops.
+func (u *Device) Command(dest byte, cmd byte, data []byte) ([]byte, error) {
+	if len(data) > maxCmdData {
+		return nil, ErrCommandData
+	}
+	_, end := u.span("mpic.Command")
+	icnt, ibuf, err := u.sepgCmd(dest, cmd, byte(len(data)), data)
+	end(err)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, icnt)
+	copy(out, ibuf[:icnt])
+	return out, nil
+}
+
+// CommandInto function is Command receiving IN data directly into dst and
+// returning the IN byte count. For IN commands dst must hold at least one
+// full packet (64 bytes). dst is owned by the caller again once
+// CommandInto returns.
+func (u *Device) CommandInto(dst []byte, dest byte, cmd byte, data []byte) (int, error) {
+	if len(data) > maxCmdData {
+		return 0, ErrCommandData
+	}
+	if protocol.IsIn(cmd) && len(dst) < maxPacketSize {
+		return 0, io.ErrShortBuffer
+	}
+	_, end := u.span("mpic.CommandInto")
+	icnt, _, err := u.sepgCmdInto(dst, dest, cmd, byte(len(data)), data)
+	end(err)
+	return icnt, err
+}
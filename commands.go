@@ -0,0 +1,56 @@
+package mpic
+
+import (
+	"fmt"
+
+	"github.com/richardnwinder/mpic/protocol"
+)
+
+const (
+	destMp4x   = protocol.DestMp4x
+	cmdVersion = protocol.CmdVersion
+)
+
+// CommandInfo describes one firmware command, see package protocol
+type CommandInfo = protocol.CommandInfo
+
+// ListCommands function returns the commands supported by the negotiated
+// firmware version, or all commands known to the driver before Negotiate.
+// Drivers expose their commands with a Commands() []CommandInfo method.
+func (u *Device) ListCommands() []CommandInfo {
+	l, ok := u.drv.(interface{ Commands() []CommandInfo })
+	if !ok {
+		return nil
+	}
+	var cmds []CommandInfo
+	for _, c := range l.Commands() {
+		if u.verl == 0 || c.Supports(u.verl) {
+			cmds = append(cmds, c)
+		}
+	}
+	return cmds
+}
+
+// ValidateCommand function checks cmd with data to dest against the
+// command list of the connected firmware, for validating scripts before
+// running them with Command
+func (u *Device) ValidateCommand(dest byte, cmd byte, data []byte) error {
+	if len(data) > maxCmdData {
+		return ErrCommandData
+	}
+	for _, c := range u.ListCommands() {
+		if c.Dest != dest || c.Opcode != cmd {
+			continue
+		}
+		if len(data) < c.MinData || len(data) > c.MaxData {
+			return fmt.Errorf("Command %s takes %d to %d data bytes, got %d", c.Name, c.MinData, c.MaxData, len(data))
+		}
+		return nil
+	}
+	return fmt.Errorf("Command 0x%02x to %d not supported by version %d", cmd, dest, u.verl)
+}
+
+// Commands function returns the mp4x command table
+func (mp4xDriver) Commands() []CommandInfo {
+	return protocol.Commands()
+}
@@ -0,0 +1,271 @@
+// Package config loads mpic settings for tools, daemons and library users
+// from a file in a TOML subset: [section] headers, key = value lines with
+// "strings", integers and true/false, and # comments. Example:
+//
+//	[device]
+//	driver = "mp4x"          # registered driver name
+//	serial = ""              # USB serial of the device, empty for any
+//	remote = ""              # host:port of a remote.Serve, empty for USB
+//	secret = ""              # remote.WithSecret shared secret
+//	simulator = false        # use the built-in simulator
+//	interface = 0            # interface claimed after open
+//
+//	[timeouts]
+//	command = "1s"           # worst case EP1 wait, empty for defaults
+//	adaptive = false         # follow the observed latency
+//
+//	[logging]
+//	debug = false            # log every command to stderr
+//
+//	[options]
+//	low_memory = false
+//	audit_log = ""           # append-only audit log file
+//	metrics = ""             # expvar prefix, empty disables
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richardnwinder/mpic"
+	"github.com/richardnwinder/mpic/remote"
+	"github.com/richardnwinder/mpic/transport"
+)
+
+// Config holds the settings read from a file
+type Config struct {
+	Driver    string
+	Serial    string /* mp4x only, see mpic.OpenSerial */
+	Remote    string
+	Secret    string
+	Simulator bool
+	Interface int
+
+	Timeout  time.Duration /* 0 for the package defaults */
+	Adaptive bool
+
+	Debug bool
+
+	LowMemory bool
+	AuditLog  string
+	Metrics   string
+
+	audit *os.File /* opened by the first Options call */
+}
+
+/* expvar names can be published once per process */
+var (
+	metricsMu sync.Mutex
+	metrics   = make(map[string]*mpic.ExpvarMetrics)
+)
+
+func expvarMetrics(prefix string) *mpic.ExpvarMetrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	m, ok := metrics[prefix]
+	if !ok {
+		m = mpic.NewExpvarMetrics(prefix)
+		metrics[prefix] = m
+	}
+	return m
+}
+
+// Default function returns the settings used when no file is given
+func Default() *Config {
+	return &Config{Driver: "mp4x"}
+}
+
+// Load function reads the config file at path
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Parse function reads a config from r, starting from Default
+func Parse(r io.Reader) (*Config, error) {
+	c := Default()
+	sc := bufio.NewScanner(r)
+	section := ""
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(sc.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: bad section header", n)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		if err := c.set(section+"."+strings.TrimSpace(key), strings.TrimSpace(val)); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	return c, sc.Err()
+}
+
+// stripComment removes a # comment outside of quoted strings
+func stripComment(s string) string {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+func (c *Config) set(key string, val string) error {
+	var err error
+	switch key {
+	case "device.driver":
+		c.Driver, err = parseString(val)
+	case "device.serial":
+		c.Serial, err = parseString(val)
+	case "device.remote":
+		c.Remote, err = parseString(val)
+	case "device.secret":
+		c.Secret, err = parseString(val)
+	case "device.simulator":
+		c.Simulator, err = strconv.ParseBool(val)
+	case "device.interface":
+		c.Interface, err = strconv.Atoi(val)
+	case "timeouts.command":
+		var s string
+		if s, err = parseString(val); err == nil && s != "" {
+			c.Timeout, err = time.ParseDuration(s)
+		}
+	case "timeouts.adaptive":
+		c.Adaptive, err = strconv.ParseBool(val)
+	case "logging.debug":
+		c.Debug, err = strconv.ParseBool(val)
+	case "options.low_memory":
+		c.LowMemory, err = strconv.ParseBool(val)
+	case "options.audit_log":
+		c.AuditLog, err = parseString(val)
+	case "options.metrics":
+		c.Metrics, err = parseString(val)
+	default:
+		return fmt.Errorf("unknown key %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	return nil
+}
+
+func parseString(val string) (string, error) {
+	if !strings.HasPrefix(val, `"`) {
+		return "", fmt.Errorf("expected quoted string")
+	}
+	return strconv.Unquote(val)
+}
+
+// Options function returns the Open options for the settings. The audit
+// log file, if any, is opened for appending by the first call and stays
+// open until Close. Metrics with the same prefix are shared, since expvar
+// names can only be published once.
+func (c *Config) Options() ([]mpic.Option, error) {
+	var opts []mpic.Option
+	if c.Timeout > 0 {
+		opts = append(opts, mpic.WithTimeout(c.Timeout))
+	}
+	if c.Adaptive {
+		opts = append(opts, mpic.WithAdaptiveTimeout())
+	}
+	if c.Debug {
+		opts = append(opts, mpic.WithDebugLog(log.New(os.Stderr, "", log.LstdFlags)))
+	}
+	if c.LowMemory {
+		opts = append(opts, mpic.WithLowMemory())
+	}
+	if c.AuditLog != "" {
+		if c.audit == nil {
+			f, err := os.OpenFile(c.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+			if err != nil {
+				return nil, err
+			}
+			c.audit = f
+		}
+		opts = append(opts, mpic.WithAuditLog(c.audit))
+	}
+	if c.Metrics != "" {
+		opts = append(opts, mpic.WithMetrics(expvarMetrics(c.Metrics)))
+	}
+	return opts, nil
+}
+
+// Open function opens and claims the device selected by the settings,
+// with extra options applied after the configured ones
+func (c *Config) Open(extra ...mpic.Option) (*mpic.Device, error) {
+	opts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, extra...)
+	var dev *mpic.Device
+	switch {
+	case c.Simulator:
+		dev, err = mpic.OpenTransport(transport.NewSimulator(2, 1), opts...)
+	case c.Remote != "":
+		var t *remote.Transport
+		if t, err = remote.Dial(c.Remote, remote.WithSecret(c.Secret)); err == nil {
+			dev, err = mpic.OpenTransport(t, opts...) /* closes t on error */
+		}
+	case c.Serial != "":
+		if c.Driver != "mp4x" {
+			return nil, fmt.Errorf("serial selection is not supported by driver %s", c.Driver)
+		}
+		dev, err = mpic.OpenSerial(c.Serial, opts...)
+	default:
+		dev, err = mpic.OpenDriver(c.Driver, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := dev.ClaimInterface(uint32(c.Interface)); err != nil {
+		dev.Close()
+		return nil, err
+	}
+	return dev, nil
+}
+
+// Close function closes the audit log opened by Options, devices using it
+// must be closed first
+func (c *Config) Close() error {
+	if c.audit == nil {
+		return nil
+	}
+	err := c.audit.Close()
+	c.audit = nil
+	return err
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want Config
+		err  string
+	}{
+		{name: "empty", want: Config{Driver: "mp4x"}},
+		{name: "full", in: `
+[device]
+driver = "mp4x"
+serial = "A1"
+remote = "127.0.0.1:7342"
+secret = "s"
+simulator = true
+interface = 1
+[timeouts]
+command = "500ms"
+adaptive = true
+[logging]
+debug = true
+[options]
+low_memory = true
+audit_log = "/tmp/a.log"
+metrics = "mpic"
+`, want: Config{Driver: "mp4x", Serial: "A1", Remote: "127.0.0.1:7342", Secret: "s",
+			Simulator: true, Interface: 1, Timeout: 500 * time.Millisecond, Adaptive: true,
+			Debug: true, LowMemory: true, AuditLog: "/tmp/a.log", Metrics: "mpic"}},
+		{name: "comments", in: "# top\n[device] # section\nserial = \"A1\" # trailing\n  # indented\n",
+			want: Config{Driver: "mp4x", Serial: "A1"}},
+		{name: "hash in string", in: "[device]\nserial = \"A#1\" # real comment\n",
+			want: Config{Driver: "mp4x", Serial: "A#1"}},
+		{name: "escaped quote", in: "[device]\nserial = \"A\\\"#1\"\n",
+			want: Config{Driver: "mp4x", Serial: "A\"#1"}},
+		{name: "empty command timeout", in: "[timeouts]\ncommand = \"\"\n",
+			want: Config{Driver: "mp4x"}},
+		{name: "unknown key", in: "[device]\nspeed = 1\n", err: "line 2: unknown key device.speed"},
+		{name: "key outside section", in: "serial = \"A1\"\n", err: "unknown key .serial"},
+		{name: "unquoted string", in: "[device]\nserial = A1\n", err: "expected quoted string"},
+		{name: "unterminated string", in: "[device]\nserial = \"A1\n", err: "device.serial"},
+		{name: "bad bool", in: "[device]\nsimulator = yes\n", err: "device.simulator"},
+		{name: "bad int", in: "[device]\ninterface = \"0\"\n", err: "device.interface"},
+		{name: "bad duration", in: "[timeouts]\ncommand = \"soon\"\n", err: "timeouts.command"},
+		{name: "bad section", in: "[device\n", err: "line 1: bad section header"},
+		{name: "no value", in: "[device]\nserial\n", err: "line 2: expected key = value"},
+	} {
+		c, err := Parse(strings.NewReader(tc.in))
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: error %v, want %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if *c != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, *c, tc.want)
+		}
+	}
+}
+
+func TestOptionsTwice(t *testing.T) {
+	c := Default()
+	c.Metrics = "mpic_config_test"
+	c.AuditLog = t.TempDir() + "/audit.log"
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := c.Options(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Simulator = true
+	for i := 0; i < 2; i++ {
+		d, err := c.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Close()
+	}
+}
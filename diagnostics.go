@@ -0,0 +1,93 @@
+package mpic
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Capabilities holds the version dependant limits selected at negotiation
+type Capabilities struct {
+	Driver   string `json:"driver"`
+	Version  int    `json:"version"` /* 10*ver + rls, 0 if not negotiated */
+	Type     string `json:"type"`    /* MP designation "4", "5", "6", "7" */
+	Sbmax    int    `json:"sbmax"`
+	Lbmax    int    `json:"lbmax"`
+	Ibeht    int    `json:"ibeht"`
+	Ibrcv    int    `json:"ibrcv"`
+	Dcmax    int    `json:"dcmax"`
+	Apidx    int    `json:"apidx"`
+	DcrtSecs int    `json:"dcrt_secs"`
+	CreateMs int    `json:"create_eht_ms"`
+	LoadMs   int    `json:"download_eht_ms"`
+}
+
+// Capabilities function returns the negotiated version dependant limits
+func (u *Device) Capabilities() Capabilities {
+	c := Capabilities{
+		Driver:   u.Driver(),
+		Version:  u.verl,
+		Sbmax:    u.sbmax,
+		Lbmax:    u.lbmax,
+		Ibeht:    u.ibeht,
+		Ibrcv:    u.ibrcv,
+		Dcmax:    u.dcmax,
+		Apidx:    u.apcsiz,
+		DcrtSecs: int(u.mdcrt),
+		CreateMs: u.cehwt,
+		LoadMs:   u.dehwt,
+	}
+	if u.mtv != 0 {
+		c.Type = string(rune(u.mtv))
+	}
+	return c
+}
+
+// Diagnostics function writes a tar archive describing the device state
+// for support tickets: version, capabilities, USB ids, power state, health
+// report, recent command trace and error counters. EHT, DCRT and APIDX
+// contents are not included since this package does not read them yet.
+func (u *Device) Diagnostics(w io.Writer) (err error) {
+	_, end := u.span("mpic.Diagnostics")
+	defer func() { end(err) }()
+	iver, irls, verr := u.GetVersion()
+	version := map[string]interface{}{"version": iver, "release": irls}
+	if verr != nil {
+		version["error"] = verr.Error()
+	}
+	health, _ := u.HealthCheck(u.opContext())
+	power, _ := u.PowerState() /* connection times even without USB fields */
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"version.json", version},
+		{"capabilities.json", u.Capabilities()},
+		{"usb.json", map[string]interface{}{
+			"vid": mp42Vid, "pid": mp42Pid, "ep1in": u.epIn, "ep1out": u.epOut,
+		}},
+		{"power.json", power},
+		{"health.json", health},
+		{"trace.json", u.Trace()},
+		{"errors.json", u.ErrorCounts()},
+	}
+
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for i, f := range files {
+		data, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(data)), ModTime: now}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err = tw.Write(data); err != nil {
+			return err
+		}
+		u.progress("diagnostics", int64(i+1), int64(len(files)), f.name)
+	}
+	return tw.Close()
+}
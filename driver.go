@@ -0,0 +1,161 @@
+package mpic
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/richardnwinder/mpic/transport"
+)
+
+// Driver is one device family protocol running on the common transport.
+// Drivers register themselves with RegisterDriver, usually from init.
+// A transport returned by Open may report the USB serial number of the
+// device with a Serial() string method, used for labels and PowerState.
+type Driver interface {
+	Name() string
+	Open() (Transport, error)  /* open the family's USB device */
+	Negotiate(u *Device) error /* query the version and SetCapabilities */
+}
+
+// ErrUnknownDriver is returned by OpenDriver for unregistered names
+var ErrUnknownDriver = errors.New("Unknown driver")
+
+const defaultDriver = "mp4x"
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// RegisterDriver function makes a driver available by its name. It panics
+// if d is nil or a driver with the same name is already registered.
+func RegisterDriver(d Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if d == nil {
+		panic("mpic: RegisterDriver driver is nil")
+	}
+	if _, dup := drivers[d.Name()]; dup {
+		panic("mpic: RegisterDriver called twice for driver " + d.Name())
+	}
+	drivers[d.Name()] = d
+}
+
+// Drivers function returns the sorted names of the registered drivers
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for n := range drivers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupDriver(name string) (Driver, error) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	d, ok := drivers[name]
+	if !ok {
+		return nil, ErrUnknownDriver
+	}
+	return d, nil
+}
+
+// OpenDriver function connects a device of the family handled by the
+// named driver
+func OpenDriver(name string, opts ...Option) (*Device, error) {
+	d, err := lookupDriver(name)
+	if err != nil {
+		return nil, err
+	}
+	/* checked before the open so a bad variable does not leak the device */
+	envOpts, err := envOptions()
+	if err != nil {
+		return nil, err
+	}
+	t, err := d.Open()
+	if err != nil {
+		return nil, err
+	}
+	var serial string
+	if s, ok := t.(interface{ Serial() string }); ok {
+		serial = s.Serial()
+	}
+	return openTransport(t, append([]Option{WithDriver(d), withReopen(d.Open), func(u *Device) {
+		u.serial = serial
+	}}, opts...), envOpts)
+}
+
+// WithDriver option selects the driver of a Device opened with
+// OpenTransport, the default is "mp4x"
+func WithDriver(d Driver) Option {
+	return func(u *Device) {
+		u.drv = d
+	}
+}
+
+// Driver function returns the name of the Device driver
+func (u *Device) Driver() string {
+	return u.drv.Name()
+}
+
+// SetCapabilities function installs the version dependant limits, used by
+// drivers from Negotiate. Buffers are resized to the new limits.
+func (u *Device) SetCapabilities(c Capabilities) error {
+	if c.Sbmax <= 0 || c.Lbmax <= 0 || c.Ibeht <= 0 || c.Ibrcv <= 0 || c.Dcmax <= 0 {
+		return errors.New("Bad capabilities")
+	}
+	u.verl = c.Version
+	u.ver = byte(c.Version)
+	u.iver = c.Version / 10
+	u.irls = c.Version % 10
+	u.mtv = 0
+	if c.Type != "" {
+		u.mtv = c.Type[0]
+	}
+	u.sbmax = c.Sbmax
+	u.lbmax = c.Lbmax
+	u.ibeht = c.Ibeht
+	u.ibrcv = c.Ibrcv
+	u.dcmax = c.Dcmax
+	u.apcsiz = c.Apidx
+	u.mdcrt = byte(c.DcrtSecs)
+	u.cehwt = c.CreateMs
+	u.dehwt = c.LoadMs
+	err := u.applyLimits()
+	u.sizeBuffers()
+	return err
+}
+
+/* mp4x is the built-in driver for the MP42 family (VID 0x04d8, PID 0xfca7) */
+type mp4xDriver struct{}
+
+func (mp4xDriver) Name() string { return defaultDriver }
+
+func (mp4xDriver) Open() (Transport, error) {
+	if err := checkSerial(); err != nil {
+		return nil, err
+	}
+	t, err := transport.OpenUSB()
+	if err != nil {
+		return nil, err
+	}
+	return mp4xTransport{Transport: t, serial: attachedSerial()}, nil
+}
+
+/* mp4xTransport adds the serial found in sysfs to the usb device */
+type mp4xTransport struct {
+	Transport
+	serial string
+}
+
+func (t mp4xTransport) Serial() string { return t.serial }
+
+func (mp4xDriver) Negotiate(u *Device) error { return u.sepgGetSetVersion() }
+
+func init() {
+	RegisterDriver(mp4xDriver{})
+}
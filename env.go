@@ -0,0 +1,93 @@
+package mpic
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables applied by Open, OpenSerial and OpenDriver
+const (
+	EnvTimeout   = "MPIC_TIMEOUT"   /* fixed EP1 timeout, e.g. "500ms" (see WithTimeout) */
+	EnvSerial    = "MPIC_SERIAL"    /* serial number the attached device must have */
+	EnvDebug     = "MPIC_DEBUG"     /* "1" or "true" logs every command to stderr */
+	EnvEndpoints = "MPIC_ENDPOINTS" /* command endpoints "in,out", e.g. "0x81,0x01" */
+)
+
+// ErrSerialMismatch is returned by Open and OpenSerial when the serial
+// does not select exactly one attached device
+var ErrSerialMismatch = errors.New("Serial does not match the attached device")
+
+// envOptions returns the options set by the MPIC_* environment variables
+func envOptions() ([]Option, error) {
+	var opts []Option
+	if v := os.Getenv(EnvTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s: bad duration %q", EnvTimeout, v)
+		}
+		opts = append(opts, WithTimeout(d))
+	}
+	if v := os.Getenv(EnvDebug); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad value %q", EnvDebug, v)
+		}
+		if on {
+			opts = append(opts, WithDebugLog(log.New(os.Stderr, "", log.LstdFlags)))
+		}
+	}
+	if v := os.Getenv(EnvEndpoints); v != "" {
+		in, out, ok := strings.Cut(v, ",")
+		epIn, err1 := strconv.ParseUint(strings.TrimSpace(in), 0, 8)
+		epOut, err2 := strconv.ParseUint(strings.TrimSpace(out), 0, 8)
+		if !ok || err1 != nil || err2 != nil || epIn&0x80 == 0 || epOut&0x80 != 0 {
+			return nil, fmt.Errorf("%s: expected \"in,out\" like \"0x81,0x01\", got %q", EnvEndpoints, v)
+		}
+		opts = append(opts, func(u *Device) {
+			u.epIn = uint32(epIn)
+			u.epOut = uint32(epOut)
+		})
+	}
+	return opts, nil
+}
+
+// checkSerial verifies MPIC_SERIAL before a USB open. The usb library
+// opens the first device with the VID/PID, so the serial can only be
+// honoured when that device is the only one attached.
+func checkSerial() error {
+	want := os.Getenv(EnvSerial)
+	if want == "" {
+		return nil
+	}
+	if err := selectSerial(want); err != nil {
+		return fmt.Errorf("%s: %w", EnvSerial, err)
+	}
+	return nil
+}
+
+// selectSerial verifies that want is the serial of the only attached device
+func selectSerial(want string) error {
+	devs, err := List()
+	if err != nil {
+		return err
+	}
+	if len(devs) != 1 || devs[0].Serial != want {
+		return fmt.Errorf("%w: want %q, %d device(s) attached", ErrSerialMismatch, want, len(devs))
+	}
+	return nil
+}
+
+// OpenSerial function connects the mp4x device with USB serial number
+// serial. It fails with ErrSerialMismatch unless that device is the only
+// one attached, since the usb library opens by VID/PID.
+func OpenSerial(serial string, opts ...Option) (*Device, error) {
+	if err := selectSerial(serial); err != nil {
+		return nil, err
+	}
+	return Open(opts...)
+}
@@ -0,0 +1,59 @@
+package mpic
+
+import "testing"
+
+func TestEnvOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		timeout, debug string
+		endpoints      string
+		fail           bool
+		opts           int
+		tmo            uint32
+		epIn, epOut    uint32
+	}{
+		{name: "unset", epIn: 0x81, epOut: 0x01},
+		{name: "timeout", timeout: "250ms", opts: 1, tmo: 250, epIn: 0x81, epOut: 0x01},
+		{name: "bad duration", timeout: "fast", fail: true},
+		{name: "zero duration", timeout: "0s", fail: true},
+		{name: "negative duration", timeout: "-1s", fail: true},
+		{name: "debug on", debug: "true", opts: 1, epIn: 0x81, epOut: 0x01},
+		{name: "debug off", debug: "0", epIn: 0x81, epOut: 0x01},
+		{name: "bad bool", debug: "yes", fail: true},
+		{name: "endpoints", endpoints: "0x82, 0x02", opts: 1, epIn: 0x82, epOut: 0x02},
+		{name: "endpoints decimal", endpoints: "131,3", opts: 1, epIn: 0x83, epOut: 0x03},
+		{name: "in without direction bit", endpoints: "0x01,0x01", fail: true},
+		{name: "out with direction bit", endpoints: "0x81,0x81", fail: true},
+		{name: "swapped endpoints", endpoints: "0x01,0x81", fail: true},
+		{name: "one endpoint", endpoints: "0x81", fail: true},
+		{name: "endpoint too large", endpoints: "0x181,0x01", fail: true},
+		{name: "all", timeout: "1s", debug: "1", endpoints: "0x81,0x01", opts: 3, tmo: 1000, epIn: 0x81, epOut: 0x01},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvTimeout, tc.timeout)
+			t.Setenv(EnvDebug, tc.debug)
+			t.Setenv(EnvEndpoints, tc.endpoints)
+			opts, err := envOptions()
+			if tc.fail {
+				if err == nil {
+					t.Fatalf("envOptions succeeded with %d options", len(opts))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(opts) != tc.opts {
+				t.Fatalf("%d options, want %d", len(opts), tc.opts)
+			}
+			u := &Device{epIn: 0x81, epOut: 0x01}
+			for _, o := range opts {
+				o(u)
+			}
+			if u.tmo != tc.tmo || u.epIn != tc.epIn || u.epOut != tc.epOut {
+				t.Errorf("tmo %d, endpoints 0x%02x,0x%02x, want %d, 0x%02x,0x%02x",
+					u.tmo, u.epIn, u.epOut, tc.tmo, tc.epIn, tc.epOut)
+			}
+		})
+	}
+}
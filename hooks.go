@@ -0,0 +1,66 @@
+package mpic
+
+import "log"
+
+// Hooks holds lifecycle callbacks run by Device. Callbacks run on the
+// goroutine performing the operation and must not block for long.
+type Hooks struct {
+	OnOpen      func(*Device)             /* after Open succeeded */
+	OnClose     func(*Device)             /* after Close */
+	OnReconnect func(*Device, error)      /* after Reconnect, err is its result */
+	OnCommand   func(*Device, TraceEntry) /* after each command finished */
+}
+
+type hookSet struct {
+	open      []func(*Device)
+	close     []func(*Device)
+	reconnect []func(*Device, error)
+	command   []func(*Device, TraceEntry)
+}
+
+// WithHooks option registers lifecycle callbacks, nil fields are ignored
+func WithHooks(h Hooks) Option {
+	return func(u *Device) {
+		if h.OnOpen != nil {
+			u.hooks.open = append(u.hooks.open, h.OnOpen)
+		}
+		if h.OnClose != nil {
+			u.OnClose(h.OnClose)
+		}
+		if h.OnReconnect != nil {
+			u.OnReconnect(h.OnReconnect)
+		}
+		if h.OnCommand != nil {
+			u.OnCommand(h.OnCommand)
+		}
+	}
+}
+
+// OnClose function registers f to run after Close
+func (u *Device) OnClose(f func(*Device)) {
+	u.hooks.close = append(u.hooks.close, f)
+}
+
+// OnReconnect function registers f to run after each Reconnect
+func (u *Device) OnReconnect(f func(*Device, error)) {
+	u.hooks.reconnect = append(u.hooks.reconnect, f)
+}
+
+// OnCommand function registers f to run after each command
+func (u *Device) OnCommand(f func(*Device, TraceEntry)) {
+	u.hooks.command = append(u.hooks.command, f)
+}
+
+// WithDebugLog option logs every command with its size, latency and error
+// to l
+func WithDebugLog(l *log.Logger) Option {
+	return func(u *Device) {
+		u.OnCommand(func(u *Device, e TraceEntry) {
+			if e.Error != "" {
+				l.Printf("mpic: cmd 0x%02x out %d in %d %v error: %s", e.Cmd, e.Out, e.In, e.Latency, e.Error)
+				return
+			}
+			l.Printf("mpic: cmd 0x%02x out %d in %d %v", e.Cmd, e.Out, e.In, e.Latency)
+		})
+	}
+}
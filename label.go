@@ -0,0 +1,149 @@
+package mpic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxLabelLen = 32
+
+// ErrNoSerial is returned by label functions when the device serial is unknown
+var ErrNoSerial = errors.New("Device serial unknown")
+
+var labelMu sync.Mutex
+
+// labelFile returns the host label registry, a JSON object of serial to
+// label under the user config directory. The firmware has no known
+// configuration memory command, so labels are kept on the host.
+func labelFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mpic", "labels.json"), nil
+}
+
+func readLabels() (map[string]string, error) {
+	path, err := labelFile()
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return labels, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return labels, nil
+}
+
+// LabelOf function returns the label stored for serial, "" if none
+func LabelOf(serial string) (string, error) {
+	labelMu.Lock()
+	defer labelMu.Unlock()
+	labels, err := readLabels()
+	if err != nil {
+		return "", err
+	}
+	return labels[serial], nil
+}
+
+// SetLabelOf function stores label for serial, an empty label removes it.
+// Labels are at most 32 printable characters.
+func SetLabelOf(serial string, label string) error {
+	if serial == "" {
+		return ErrNoSerial
+	}
+	if utf8.RuneCountInString(label) > maxLabelLen {
+		return fmt.Errorf("Label longer than %d characters", maxLabelLen)
+	}
+	for _, r := range label {
+		if !unicode.IsPrint(r) {
+			return errors.New("Label has non printable characters")
+		}
+	}
+	labelMu.Lock()
+	defer labelMu.Unlock()
+	labels, err := readLabels()
+	if err != nil {
+		return err
+	}
+	if label == "" {
+		delete(labels, serial)
+	} else {
+		labels[serial] = label
+	}
+	path, err := labelFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(labels, "", "  ")
+	if err != nil {
+		return err
+	}
+	/* unique temp file, concurrent processes must not share one */
+	f, err := os.CreateTemp(filepath.Dir(path), "labels-*.json")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Serial function returns the USB serial number of the device, "" when
+// it could not be determined (several devices attached or not on Linux)
+func (u *Device) Serial() string {
+	return u.serial
+}
+
+// GetLabel function returns the label of the device
+func (u *Device) GetLabel() (string, error) {
+	if u.serial == "" {
+		return "", ErrNoSerial
+	}
+	return LabelOf(u.serial)
+}
+
+// SetLabel function sets the label of the device, e.g. "Station 3 dongle"
+func (u *Device) SetLabel(label string) error {
+	if u.serial == "" {
+		return ErrNoSerial
+	}
+	return SetLabelOf(u.serial, label)
+}
+
+// attachedSerial returns the serial of the only attached device, the one
+// the usb library opens by VID/PID
+func attachedSerial() string {
+	devs, err := List()
+	if err != nil || len(devs) != 1 {
+		return ""
+	}
+	return devs[0].Serial
+}
@@ -0,0 +1,99 @@
+package mpic
+
+import "fmt"
+
+const (
+	lowMemCap = maxEcdLsize /* low memory cap for EP2 buffers (512) */
+	hardMax   = maxUsbDsize /* largest EP2 transfer of any firmware (16k) */
+)
+
+// Limits overrides the version table buffer sizes, zero fields keep the
+// table value
+type Limits struct {
+	Sbmax int /* short EP2 buffer */
+	Lbmax int /* long EP2 buffer */
+	Ibrcv int /* EP2 IN receive size */
+	Dcmax int /* decode buffer */
+}
+
+// Validate function checks the limits against the hard firmware maxima.
+// An override is also checked against the version table value it is
+// combined with when the version is negotiated.
+func (l Limits) Validate() error {
+	for _, f := range []struct {
+		name string
+		v    int
+	}{{"sbmax", l.Sbmax}, {"lbmax", l.Lbmax}, {"ibrcv", l.Ibrcv}, {"dcmax", l.Dcmax}} {
+		if f.v < 0 || f.v > hardMax {
+			return fmt.Errorf("Limit %s = %d outside 0..%d", f.name, f.v, hardMax)
+		}
+	}
+	if l.Sbmax != 0 && l.Lbmax != 0 && l.Sbmax > l.Lbmax {
+		return fmt.Errorf("Limit sbmax = %d above lbmax = %d", l.Sbmax, l.Lbmax)
+	}
+	return nil
+}
+
+// WithLimits option overrides the buffer size limits selected by version
+// negotiation, for firmware builds whose real limits differ from the
+// version table. Open fails if l does not validate, Negotiate if the
+// resulting sbmax exceeds lbmax.
+func WithLimits(l Limits) Option {
+	return func(u *Device) {
+		if err := l.Validate(); err != nil {
+			u.optErr = err
+			return
+		}
+		u.limits = l
+	}
+}
+
+// WithLowMemory option caps the EP2 transfer sizes and buffers at the v1.2
+// values (256 short, 512 long) whatever the version allows, trading
+// throughput for footprint on small hosts
+func WithLowMemory() Option {
+	return func(u *Device) {
+		u.lowmem = true
+	}
+}
+
+// applyLimits adjusts the version table limits before buffers are sized.
+// Overrides that do not fit the table are not applied and reported.
+func (u *Device) applyLimits() error {
+	sb, lb := u.sbmax, u.lbmax
+	if u.limits.Sbmax != 0 {
+		sb = u.limits.Sbmax
+	}
+	if u.limits.Lbmax != 0 {
+		lb = u.limits.Lbmax
+	}
+	var err error
+	if sb > lb {
+		err = fmt.Errorf("Limit sbmax = %d above lbmax = %d for version %d", sb, lb, u.verl)
+	} else {
+		u.applyOverrides()
+	}
+	if u.lowmem {
+		u.sbmax = min(u.sbmax, maxEcdBsize)
+		u.lbmax = min(u.lbmax, lowMemCap)
+		u.ibeht = min(u.ibeht, lowMemCap)
+		u.ibrcv = min(u.ibrcv, lowMemCap)
+		u.dcmax = min(u.dcmax, maxEcdBsize)
+	}
+	return err
+}
+
+func (u *Device) applyOverrides() {
+	if u.limits.Sbmax != 0 {
+		u.sbmax = u.limits.Sbmax
+	}
+	if u.limits.Lbmax != 0 {
+		u.lbmax = u.limits.Lbmax
+	}
+	if u.limits.Ibrcv != 0 {
+		u.ibrcv = u.limits.Ibrcv
+	}
+	if u.limits.Dcmax != 0 {
+		u.dcmax = u.limits.Dcmax
+	}
+}
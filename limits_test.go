@@ -0,0 +1,51 @@
+package mpic
+
+import "testing"
+
+func TestLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		iver, irls   int
+		limits       Limits
+		lowmem       bool
+		fail         bool
+		sbmax, lbmax int
+		ob, ib       int
+	}{
+		{name: "v1.2 table", iver: 1, irls: 2, sbmax: 256, lbmax: 512, ob: 512, ib: 512},
+		{name: "v2.1 table", iver: 2, irls: 1, sbmax: 0x400, lbmax: 0x700, ob: 0x700, ib: 0x4000},
+		{name: "v3.0 table", iver: 3, irls: 0, sbmax: 0x400, lbmax: 0x700, ob: 0x700, ib: 0x4000},
+		{name: "v1.2 sbmax above table lbmax", iver: 1, irls: 2, limits: Limits{Sbmax: 0x400},
+			fail: true, sbmax: 256, lbmax: 512, ob: 512, ib: 512},
+		{name: "v2.1 lbmax below table sbmax", iver: 2, irls: 1, limits: Limits{Lbmax: 0x200},
+			fail: true, sbmax: 0x400, lbmax: 0x700, ob: 0x700, ib: 0x4000},
+		{name: "v2.1 overrides", iver: 2, irls: 1, limits: Limits{Sbmax: 0x200, Lbmax: 0x1000, Ibrcv: 0x800},
+			sbmax: 0x200, lbmax: 0x1000, ob: 0x1000, ib: 0x2000},
+		{name: "v3.0 lowmem overrides", iver: 3, irls: 0, limits: Limits{Sbmax: 0x800, Lbmax: 0x1000},
+			lowmem: true, sbmax: 256, lbmax: 512, ob: 512, ib: 512},
+		{name: "v1.2 lowmem small sbmax", iver: 1, irls: 2, limits: Limits{Sbmax: 0x80},
+			lowmem: true, sbmax: 0x80, lbmax: 512, ob: 512, ib: 512},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []Option{WithLimits(tc.limits)}
+			if tc.lowmem {
+				opts = append(opts, WithLowMemory())
+			}
+			u, err := OpenTransport(NewSimulator(tc.iver, tc.irls), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer u.Close()
+			err = u.Negotiate()
+			if tc.fail != (err != nil) {
+				t.Fatalf("Negotiate: %v, want failure %v", err, tc.fail)
+			}
+			if u.sbmax != tc.sbmax || u.lbmax != tc.lbmax {
+				t.Errorf("sbmax, lbmax = %d, %d, want %d, %d", u.sbmax, u.lbmax, tc.sbmax, tc.lbmax)
+			}
+			if len(u.ob.buf) != tc.ob || len(u.ib.buf) != tc.ib {
+				t.Errorf("ob, ib = %d, %d, want %d, %d", len(u.ob.buf), len(u.ib.buf), tc.ob, tc.ib)
+			}
+		})
+	}
+}
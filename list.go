@@ -0,0 +1,35 @@
+package mpic
+
+import "github.com/richardnwinder/mpic/transport"
+
+// ErrNotSupported is returned by functions not available on this platform
+var ErrNotSupported = transport.ErrNotSupported
+
+// DeviceInfo describes one attached mpic device
+type DeviceInfo struct {
+	Path         string `json:"path"` /* bus path, e.g. "1-1.2" */
+	Bus          int    `json:"bus"`
+	Address      int    `json:"address"`
+	Serial       string `json:"serial,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Product      string `json:"product,omitempty"`
+	Speed        string `json:"speed,omitempty"` /* Mbit/s as reported by the OS */
+	Label        string `json:"label,omitempty"` /* see SetLabelOf */
+}
+
+// List function returns the attached mpic devices (VID 0x04d8, PID 0xfca7)
+func List() ([]DeviceInfo, error) {
+	devs, err := listDevices()
+	if err != nil {
+		return nil, err
+	}
+	if len(devs) != 0 {
+		labelMu.Lock()
+		labels, _ := readLabels() /* labels are optional */
+		labelMu.Unlock()
+		for i := range devs {
+			devs[i].Label = labels[devs[i].Serial]
+		}
+	}
+	return devs, nil
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+package mpic
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const sysUsbDevices = "/sys/bus/usb/devices"
+
+func listDevices() ([]DeviceInfo, error) {
+	ents, err := os.ReadDir(sysUsbDevices)
+	if os.IsNotExist(err) { /* no USB bus on this host */
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var devs []DeviceInfo
+	for _, e := range ents {
+		dir := filepath.Join(sysUsbDevices, e.Name())
+		if sysfsHex(dir, "idVendor") != mp42Vid || sysfsHex(dir, "idProduct") != mp42Pid {
+			continue
+		}
+		devs = append(devs, DeviceInfo{
+			Path:         e.Name(),
+			Bus:          sysfsInt(dir, "busnum"),
+			Address:      sysfsInt(dir, "devnum"),
+			Serial:       sysfsString(dir, "serial"),
+			Manufacturer: sysfsString(dir, "manufacturer"),
+			Product:      sysfsString(dir, "product"),
+			Speed:        sysfsString(dir, "speed"),
+		})
+	}
+	return devs, nil
+}
+
+func sysfsString(dir string, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func sysfsInt(dir string, name string) int {
+	n, _ := strconv.Atoi(sysfsString(dir, name))
+	return n
+}
+
+func sysfsHex(dir string, name string) int {
+	n, err := strconv.ParseInt(sysfsString(dir, name), 16, 32)
+	if err != nil {
+		return -1
+	}
+	return int(n)
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package mpic
+
+func listDevices() ([]DeviceInfo, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,100 @@
+package mpic
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+)
+
+// Error classes reported to Metrics.Error
+const (
+	ErrClassSend     = "send"     /* EP1 OUT transfer failed or was short */
+	ErrClassInsync   = "insync"   /* INSYNC byte not received */
+	ErrClassRecv     = "recv"     /* EP1 IN transfer failed */
+	ErrClassResponse = "response" /* IN data has unexpected size or content */
+)
+
+// Metrics interface is the registry hook used by Device to report counters
+// and timings. Implementations must be safe for concurrent use.
+type Metrics interface {
+	Command(cmd byte)                  /* one command issued */
+	Error(class string)                /* one failure of the given class */
+	Bytes(out int, in int)             /* bytes transferred OUT and IN */
+	Latency(cmd byte, d time.Duration) /* command round-trip time */
+}
+
+// latency histogram bucket upper bounds
+var latencyBuckets = []time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	1000 * time.Millisecond,
+	2000 * time.Millisecond,
+	5000 * time.Millisecond,
+}
+
+// ExpvarMetrics is a Metrics implementation publishing through expvar
+type ExpvarMetrics struct {
+	Commands *expvar.Map /* commands total by opcode */
+	Errors   *expvar.Map /* errors total by class */
+	BytesOut *expvar.Int
+	BytesIn  *expvar.Int
+	Buckets  *expvar.Map /* cumulative latency buckets ("le_<ms>", "le_inf") */
+	Sum      *expvar.Float
+	Count    *expvar.Int
+}
+
+// NewExpvarMetrics function publishes a metrics set under the given prefix
+// (for example "mpic") and returns it. The prefix must be unique per process.
+func NewExpvarMetrics(prefix string) *ExpvarMetrics {
+	return &ExpvarMetrics{
+		Commands: expvar.NewMap(prefix + "_commands_total"),
+		Errors:   expvar.NewMap(prefix + "_errors_total"),
+		BytesOut: expvar.NewInt(prefix + "_bytes_out_total"),
+		BytesIn:  expvar.NewInt(prefix + "_bytes_in_total"),
+		Buckets:  expvar.NewMap(prefix + "_latency_seconds_bucket"),
+		Sum:      expvar.NewFloat(prefix + "_latency_seconds_sum"),
+		Count:    expvar.NewInt(prefix + "_latency_seconds_count"),
+	}
+}
+
+// Command function counts one command
+func (m *ExpvarMetrics) Command(cmd byte) {
+	m.Commands.Add(fmt.Sprintf("0x%02x", cmd), 1)
+}
+
+// Error function counts one error of class
+func (m *ExpvarMetrics) Error(class string) {
+	m.Errors.Add(class, 1)
+}
+
+// Bytes function counts transferred bytes
+func (m *ExpvarMetrics) Bytes(out int, in int) {
+	m.BytesOut.Add(int64(out))
+	m.BytesIn.Add(int64(in))
+}
+
+// Latency function records one command round-trip time
+func (m *ExpvarMetrics) Latency(cmd byte, d time.Duration) {
+	for _, b := range latencyBuckets {
+		if d <= b {
+			m.Buckets.Add(fmt.Sprintf("le_%d", b.Milliseconds()), 1)
+		}
+	}
+	m.Buckets.Add("le_inf", 1)
+	m.Sum.Add(d.Seconds())
+	m.Count.Add(1)
+}
+
+// WithMetrics option attaches a metrics registry hook to the Device
+func WithMetrics(m Metrics) Option {
+	return func(u *Device) {
+		u.metrics = m
+	}
+}
@@ -1,19 +1,20 @@
 package mpic
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
-	"github.com/richardnwinder/usb"
+	"github.com/richardnwinder/mpic/protocol"
 )
 
 const (
-	mp42Vid = 0x04d8 /* mp42 VID (Mchip) */
-	mp42Pid = 0xfca7 /* mp42 PID (MDS license) */
+	mp42Vid = protocol.VID /* mp42 VID (Mchip) */
+	mp42Pid = protocol.PID /* mp42 PID (MDS license) */
 
-	maxBufSize    = 250 /* common buffer size */
-	maxPacketSize = 64  /* max one packet size */
+	maxBufSize    = 250                    /* common buffer size */
+	maxPacketSize = protocol.MaxPacketSize /* max one packet size */
 
 	maxApidxSize  = 0x10 /* v1.4 max number of apidx indexes in the apidx[] array */
 	maxApidxLsize = 0x80 /* ver >= 2.0 increased to 128 as number of the apidx indexes */
@@ -35,8 +36,8 @@ const (
 	maxUsbDsize = 0x4000          /* 16kb size */
 	maxUsbEbuf  = 8192            /* ebuf size */
 
-	ep1in  = 0x00000081
-	ep1out = 0x00000001
+	ep1in  = protocol.EP1In
+	ep1out = protocol.EP1Out
 )
 
 type iobuf struct {
@@ -46,7 +47,7 @@ type iobuf struct {
 
 // Device structure
 type Device struct {
-	dev   *usb.Device
+	dev   Transport
 	ver   byte /* used as mp saved verl (12, 14, 20, 21) */
 	mtv   byte /* MP version type "4", "5" "6"... as speciied by ver */
 	iver  int
@@ -74,93 +75,187 @@ type Device struct {
 	apcsiz int /* current apidx size (v1.4 || ver > 2.0) */
 
 	mdcrt byte /* max dcrt sections version dependant */
+
+	metrics Metrics         /* optional metrics registry hook */
+	trace   cmdTrace        /* recent commands and error counters */
+	tracer  Tracer          /* optional tracing hook */
+	tctx    context.Context /* span context of the running operation */
+	hooks   hookSet         /* lifecycle callbacks */
+	claimed []uint32        /* claimed interfaces, restored by Reconnect */
+
+	auditlog *auditLog       /* optional log of state-changing operations */
+	adapt    adaptiveTimeout /* IN wait window state */
+	tmo      uint32          /* fixed timeout override in ms, 0 for defaults */
+
+	reopen func() (Transport, error) /* used by Reconnect, nil if not supported */
+	lowmem bool                      /* cap EP2 buffers, see WithLowMemory */
+	limits Limits                    /* buffer size overrides, see WithLimits */
+	optErr error                     /* invalid option value, fails Open */
+	drv    Driver                    /* device family protocol */
+	epIn   uint32                    /* command IN endpoint (EP1 IN) */
+	epOut  uint32                    /* command OUT endpoint (EP1 OUT) */
+	prog   Progress                  /* optional progress reports */
+
+	claimWait time.Duration /* busy retry deadline for ClaimInterface */
+	serial    string        /* USB serial number, "" if unknown */
+
+	opened      time.Time /* Open time */
+	reconnected time.Time /* last successful Reconnect */
+	reconnects  int       /* successful Reconnect calls */
 }
 
-func resetBuffer(ibuf []byte, ilen int) {
-	for icnt := 0; icnt < ilen; icnt++ {
-		ibuf[icnt] = 0x00
+// Option configures a Device in Open
+type Option func(*Device)
+
+func withReopen(f func() (Transport, error)) Option {
+	return func(u *Device) {
+		u.reopen = f
 	}
 }
 
+// resetBuffer zeroes the first ilen bytes of ibuf, the region reused by the
+// next transaction; the rest of the buffer is left untouched
+func resetBuffer(ibuf []byte, ilen int) {
+	clear(ibuf[:ilen])
+}
+
 // Open function connects mpic device
-func Open() (*Device, error) {
-	var err error
-	device, err := usb.OpenVidPid(mp42Vid, mp42Pid)
-	if err != nil {
-		return nil, err
-	}
-	mpic := &Device{
-		dev: device,
-		ob:  iobuf{cnt: 0, buf: make([]byte, maxEcdIbeht)},
-		ib:  iobuf{cnt: 0, buf: make([]byte, maxEcdIbeht)},
-		ocb: iobuf{cnt: 0, buf: make([]byte, maxEcdIbeht)},
-		icb: iobuf{cnt: 0, buf: make([]byte, maxEcdIbeht)},
-	}
-	return mpic, nil
+func Open(opts ...Option) (*Device, error) {
+	return OpenDriver(defaultDriver, opts...)
 }
 
 // Close function disconnects mpic device
 func (u *Device) Close() {
-	u.dev.Close()
+	if u.dev != nil {
+		u.dev.Close()
+		u.dev = nil
+	}
+	for _, f := range u.hooks.close {
+		f(u)
+	}
 }
 
-// ClaimInterface function connects mpic device interface
+// Reconnect function reopens mpic device and claims again the interfaces
+// claimed before, for recovery after the device dropped off the bus. If
+// the device can not be reopened the error matches ErrDeviceGone and
+// further calls fail with it until a Reconnect succeeds.
+func (u *Device) Reconnect() (err error) {
+	_, end := u.span("mpic.Reconnect")
+	defer func() {
+		end(err)
+		for _, f := range u.hooks.reconnect {
+			f(u, err)
+		}
+	}()
+	if u.reopen == nil {
+		return ErrNoReconnect
+	}
+	if u.dev != nil {
+		u.dev.Close()
+		u.dev = nil /* not closed again if the reopen fails */
+	}
+	device, err := u.reopen()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDeviceGone, err)
+	}
+	u.dev = device
+	for _, n := range u.claimed {
+		if err = u.claimRetry(n); err != nil {
+			return err
+		}
+	}
+	u.reconnected = time.Now()
+	u.reconnects++
+	return nil
+}
+
+// ClaimInterface function connects mpic device interface. When another
+// process holds it the error matches ErrDeviceBusy, see WithClaimRetry.
 func (u *Device) ClaimInterface(n uint32) error {
-	e := u.dev.ClaimInterface(n)
+	_, end := u.span("mpic.ClaimInterface")
+	e := u.claimRetry(n)
+	if e == nil {
+		u.claimed = append(u.claimed, n)
+	}
+	end(e)
 	return e
 }
 
 // ReleaseInterface function disconnects mpic device interface
 func (u *Device) ReleaseInterface(n uint32) error {
+	_, end := u.span("mpic.ReleaseInterface")
+	if u.dev == nil {
+		end(ErrDeviceGone)
+		return ErrDeviceGone
+	}
 	e := u.dev.ReleaseInterface(n)
+	if e == nil {
+		for i, c := range u.claimed {
+			if c == n {
+				u.claimed = append(u.claimed[:i], u.claimed[i+1:]...)
+				break
+			}
+		}
+	}
+	end(e)
 	return e
 }
 
-func (u *Device) sepgGetInsync(endpoint uint32) error {
-	var timeout uint32 = 3000
-	var cdata []byte
-	cdata = make([]byte, maxBufSize)
-	//var odata []byte
-	//odata = make([]byte, maxBufSize)
-	idcnt, _, err := u.dev.BulkTransfer(endpoint, 1, timeout, cdata)
+func (u *Device) sepgGetInsync(cmd byte, endpoint uint32) error {
+	timeout := u.inTimeout(cmd, insyncTimeout)
+	cdata := u.icb.buf /* INSYNC is read before the IN data, icb is free */
+	idcnt, _, err := u.bulkTransfer(endpoint, 1, timeout, cdata)
 	if err != nil {
 		return err
 	}
-	if (idcnt != 1) || (cdata[0] != byte(0xff)) {
-		return errors.New("USB insync error")
+	return protocol.CheckInsync(idcnt, cdata)
+}
+
+func (u *Device) sepgCmdExec(cmd byte, ccnt int, cbuf []byte, ibuf []byte) (int, []byte, error) {
+	s, end := u.span("mpic.command")
+	s.SetAttribute("mpic.opcode", int64(cmd))
+	s.SetAttribute("mpic.bytes_out", int64(ccnt))
+	start := time.Now()
+	idcnt, odata, class, err := u.sepgCmdXfer(cmd, ccnt, cbuf, ibuf)
+	u.record(cmd, ccnt, idcnt, time.Since(start), class, err)
+	if protocol.IsIn(cmd) {
+		u.observe(cmd, time.Since(start), err)
 	}
-	return nil
+	s.SetAttribute("mpic.bytes_in", int64(idcnt))
+	end(err)
+	return idcnt, odata, err
 }
 
-func (u *Device) sepgCmdExec(cmd byte, ccnt int, cbuf []byte) (int, []byte, error) {
-	var timeout = 1000
+// sepgCmdXfer runs one command on EP1, receiving IN data in ibuf, and
+// returns the error class on failure
+func (u *Device) sepgCmdXfer(cmd byte, ccnt int, cbuf []byte, ibuf []byte) (int, []byte, string, error) {
+	timeout := u.fixedTimeout(cmdOutTimeout)
 	/*-- send command ---*/
-	idcnt, _, err := u.dev.BulkTransfer(ep1out, uint32(ccnt), uint32(timeout), cbuf)
+	idcnt, _, err := u.bulkTransfer(u.epOut, uint32(ccnt), timeout, cbuf)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, ErrClassSend, err
 	}
 	if idcnt != ccnt {
-		return 0, nil, errors.New("Can not send USB command!")
+		return 0, nil, ErrClassSend, errors.New("Can not send USB command!")
 	}
 	/* if IN command pending */
-	if (cmd & 0x80) != 0 {
+	if protocol.IsIn(cmd) {
 
-		err := u.sepgGetInsync(ep1in) // get INSYNC on EP1 */
+		err := u.sepgGetInsync(cmd, u.epIn) // get INSYNC on EP1 */
 		if err != nil {
-			fmt.Println(err)
-			return 0, nil, errors.New("Bad INSYNC on EP1!")
+			return 0, nil, ErrClassInsync, fmt.Errorf("Bad INSYNC on EP1: %w", err)
 		}
-		var cdata []byte
-		cdata = make([]byte, maxBufSize)
+		cdata := ibuf
 
-		time.Sleep(60) // Wait until mp2 data fixed for IN request (get details)
-		idcnt, odata, err := u.dev.BulkTransfer(ep1in, uint32(maxPacketSize), uint32(timeout), cdata)
+		/* one blocking transfer, it returns as soon as the IN data is ready */
+		idcnt, odata, err := u.bulkTransfer(u.epIn, uint32(maxPacketSize), u.inTimeout(cmd, cmdInTimeout), cdata)
 		if err != nil {
-			return 0, nil, err
+			return 0, nil, ErrClassRecv, err
 		}
-		return idcnt, odata, nil
+		u.icb.cnt = idcnt
+		return idcnt, odata, "", nil
 	}
-	return 0, nil, nil
+	return 0, nil, "", nil
 }
 
 // Each command starts with 3 bytes
@@ -180,22 +275,32 @@ func (u *Device) sepgCmdExec(cmd byte, ccnt int, cbuf []byte) (int, []byte, erro
 //           command with following INSYNG and data IN if any
 //																*/
 // OCMD and ICMD are send via EP1 (endpoint 1)
+//
+// The command is built in ocb and IN data is received in icb, so returned
+// data is only valid until the next command.
 func (u *Device) sepgCmd(dest byte, cmd byte, ccnt byte, ccb []byte) (int, []byte, error) {
+	return u.sepgCmdInto(u.icb.buf, dest, cmd, ccnt, ccb)
+}
+
+// sepgCmdInto is sepgCmd receiving IN data in ibuf
+func (u *Device) sepgCmdInto(ibuf []byte, dest byte, cmd byte, ccnt byte, ccb []byte) (int, []byte, error) {
 	//fmt.Printf("dest : %d\n", dest)
 	//fmt.Printf("cmd : %d\n", cmd)
 	//fmt.Printf("ccnt : %d\n", ccnt)
 	//fmt.Printf("len(ccb) : %d\n", len(ccb))
-	var cp []byte
-	cp = make([]byte, maxBufSize)
-	cp[0] = dest
-	cp[1] = cmd
-	cp[2] = ccnt
-	var cnt = 3
-	for icnt := 0; icnt < int(ccnt); icnt++ {
-		cp[cnt] = ccb[icnt]
-		cnt++
+	cp := u.ocb.buf
+	cnt, err := protocol.EncodeCommand(cp, dest, cmd, ccb[:ccnt])
+	if err != nil {
+		return 0, nil, err
+	}
+	u.ocb.cnt = cnt
+	icnt, icb, err := u.sepgCmdExec(cmd, cnt, cp, ibuf) // execute command
+	/* OUT commands change device state */
+	if !protocol.IsIn(cmd) {
+		if aerr := u.audit(fmt.Sprintf("cmd 0x%02x", cmd), cp[3:cnt], err); aerr != nil && err == nil {
+			err = aerr
+		}
 	}
-	icnt, icb, err := u.sepgCmdExec(cmd, cnt, cp) // execute command
 	return icnt, icb, err
 }
 
@@ -206,18 +311,15 @@ func (u *Device) sepgCmd(dest byte, cmd byte, ccnt byte, ccb []byte) (int, []byt
 /* Return versin and release numbers.                         */
 /**************************************************************/
 func (u *Device) sepgGetVersion() (int, int, error) {
-	var mobuf []byte
-	mobuf = make([]byte, maxBufSize)
-	micnt, mibuf, err := u.sepgCmd(4, 0x93, 0, mobuf)
+	micnt, mibuf, err := u.sepgCmd(destMp4x, cmdVersion, 0, nil)
 	if err != nil {
 		return 0, 0, err
 	}
-	if micnt != 2 {
-		return 0, 0, errors.New("Bad Response")
+	iver, irls, err := protocol.ParseVersion(mibuf[:micnt])
+	if err != nil {
+		u.recordError(ErrClassResponse)
 	}
-	iver := int(mibuf[0])
-	irls := int(mibuf[1])
-	return iver, irls, nil
+	return iver, irls, err
 }
 
 /********************** sepg_get_set_vers ***********************/
@@ -228,7 +330,7 @@ func (u *Device) sepgGetVersion() (int, int, error) {
 /* Setup OUT/IN max EP2 buf size used in usb_bulk_read() and    */
 /* usb_bulk_write().                                            */
 /****************************************************************/
-func (u *Device) sepgGetSetVersion() {
+func (u *Device) sepgGetSetVersion() error {
 	iver, irls, err := u.sepgGetVersion()
 	if err != nil { /* on error set default as 1.2 */
 		u.iver = 1
@@ -292,27 +394,63 @@ func (u *Device) sepgGetSetVersion() {
 		u.mtv = byte('7')        /* new desig */
 		u.mdcrt = maxDcrtSecs30  /* 80 dcrt sections in use for v30 */
 	}
+	if lerr := u.applyLimits(); err == nil {
+		err = lerr
+	}
+	u.sizeBuffers()
+	return err
+}
+
+// sizeBuffers (re)allocates the EP2 iobufs to the negotiated sizes:
+// ob holds one long OUT buffer, ib the larger of EP2 IN and EHT reads.
+func (u *Device) sizeBuffers() {
+	ibsz := u.ibrcv
+	if u.ibeht > ibsz {
+		ibsz = u.ibeht
+	}
+	u.ob = resizeIobuf(u.ob, u.lbmax)
+	u.ib = resizeIobuf(u.ib, ibsz)
+}
+
+func resizeIobuf(b iobuf, size int) iobuf {
+	if len(b.buf) == size {
+		b.cnt = 0
+		return b
+	}
+	return iobuf{cnt: 0, buf: make([]byte, size)}
+}
+
+// Negotiate function runs the driver version negotiation. For mp4x it
+// requests the device version and sets up the version dependant buffer
+// sizes, limits and timeouts; if the device does not answer the v1.2
+// defaults are applied and the error is returned.
+func (u *Device) Negotiate() error {
+	_, end := u.span("mpic.Negotiate")
+	err := u.drv.Negotiate(u)
+	end(err)
+	return err
 }
 
 // GetVersion function returns version and release number for mpic device
 func (u *Device) GetVersion() (int, int, error) {
+	_, end := u.span("mpic.GetVersion")
 	iver, irls, err := u.sepgGetVersion()
+	end(err)
 	return iver, irls, err
 }
 
 // Activate function returns active flag
-func (u *Device) Activate() (int, int, error) {
+func (u *Device) Activate() (iver int, irls int, err error) {
+	_, end := u.span("mpic.Activate")
+	defer func() { end(err) }()
 	//if()
-	var mobuf []byte
-	mobuf = make([]byte, maxBufSize)
-	micnt, mibuf, err := u.sepgCmd(4, 0x93, 0, mobuf)
+	micnt, mibuf, err := u.sepgCmd(destMp4x, cmdVersion, 0, nil)
 	if err != nil {
 		return 0, 0, err
 	}
-	if micnt != 2 {
-		return 0, 0, errors.New("Bad Response")
+	iver, irls, err = protocol.ParseVersion(mibuf[:micnt])
+	if err != nil {
+		u.recordError(ErrClassResponse)
 	}
-	iver := int(mibuf[0])
-	irls := int(mibuf[1])
-	return iver, irls, nil
+	return iver, irls, err
 }
@@ -0,0 +1,117 @@
+// Package mpichttp exposes an mpic Device over HTTP for web based
+// production tooling.
+//
+// Endpoints (all GET):
+//
+//	/info         version, release and negotiated capabilities (JSON)
+//	/health       health report (JSON), status 503 when unhealthy
+//	/stats        command counters and latency distributions (JSON)
+//	/diagnostics  diagnostics tar archive
+//
+// There are no encode/decode or EHT endpoints: package mpic does not
+// implement those operations yet.
+package mpichttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/richardnwinder/mpic"
+)
+
+// Handler serves one Device, serializing access to it
+type Handler struct {
+	mu  sync.Mutex
+	dev *mpic.Device
+	mux *http.ServeMux
+}
+
+// NewHandler function returns an http.Handler serving dev
+func NewHandler(dev *mpic.Device) *Handler {
+	h := &Handler{dev: dev, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/info", h.get(h.info))
+	h.mux.HandleFunc("/health", h.get(h.health))
+	h.mux.HandleFunc("/stats", h.get(h.stats))
+	h.mux.HandleFunc("/diagnostics", h.get(h.diagnostics))
+	return h
+}
+
+// ServeHTTP function implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Lock function gives the caller exclusive use of the Device, for programs
+// sharing it between the handler and their own code
+func (h *Handler) Lock() { h.mu.Lock() }
+
+// Unlock function releases the Device taken by Lock
+func (h *Handler) Unlock() { h.mu.Unlock() }
+
+func (h *Handler) get(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		f(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (h *Handler) info(w http.ResponseWriter, r *http.Request) {
+	iver, irls, err := h.dev.GetVersion()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version":      iver,
+		"release":      irls,
+		"capabilities": h.dev.Capabilities(),
+	})
+}
+
+func (h *Handler) health(w http.ResponseWriter, r *http.Request) {
+	rep, err := h.dev.HealthCheck(r.Context())
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	status := http.StatusOK
+	if !rep.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, rep)
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.dev.Stats())
+}
+
+func (h *Handler) diagnostics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer /* a failed archive must not go out as 200 */
+	if err := h.dev.Diagnostics(&buf); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", `attachment; filename="mpic-diagnostics.tar"`)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,97 @@
+package mpichttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/richardnwinder/mpic"
+)
+
+func newServer(t *testing.T, iver, irls int) *httptest.Server {
+	t.Helper()
+	dev, err := mpic.OpenTransport(mpic.NewSimulator(iver, irls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(dev.Close)
+	srv := httptest.NewServer(NewHandler(dev))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func getJSON(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestInfo(t *testing.T) {
+	srv := newServer(t, 2, 1)
+	var info struct {
+		Version int `json:"version"`
+		Release int `json:"release"`
+	}
+	if code := getJSON(t, srv.URL+"/info", &info); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if info.Version != 2 || info.Release != 1 {
+		t.Errorf("version %d.%d, want 2.1", info.Version, info.Release)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		iver, irls int
+		code       int
+	}{
+		{"healthy", 2, 1, http.StatusOK},
+		{"unsupported version", 1, 1, http.StatusServiceUnavailable},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newServer(t, tc.iver, tc.irls)
+			var rep mpic.HealthReport
+			if code := getJSON(t, srv.URL+"/health", &rep); code != tc.code {
+				t.Errorf("status %d, want %d", code, tc.code)
+			}
+			if rep.Healthy != (tc.code == http.StatusOK) {
+				t.Errorf("healthy %v with status %d", rep.Healthy, tc.code)
+			}
+		})
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	srv := newServer(t, 2, 1)
+	resp, err := http.Get(srv.URL + "/diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-tar" {
+		t.Errorf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	srv := newServer(t, 2, 1)
+	for _, path := range []string{"/info", "/health", "/stats", "/diagnostics"} {
+		resp, err := http.Post(srv.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD" {
+			t.Errorf("POST %s: status %d, Allow %q", path, resp.StatusCode, resp.Header.Get("Allow"))
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package mpicrpc serves an mpic Device over JSON-RPC 2.0, over HTTP POST
+// or any byte stream, with batch support.
+//
+// Methods:
+//
+//	mpic.version       -> {"version": 2, "release": 1}
+//	mpic.negotiate     -> capabilities after version negotiation
+//	mpic.capabilities  -> negotiated capabilities
+//	mpic.command       {"dest": 4, "cmd": 147, "data": "base64"} -> {"data": "base64"}
+//	mpic.commands      -> firmware commands of the connected device
+//	mpic.health        -> health report
+//	mpic.stats         -> command counters and latency distributions
+//
+// mpic.command sends raw device commands and is only served for the
+// opcodes enabled with WithCommands. The server does no authentication,
+// put it behind an authenticating proxy or bind it to localhost.
+// Encode/decode and EHT methods are not provided since package mpic does
+// not implement them yet.
+package mpicrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/richardnwinder/mpic"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeDeviceError    = -32000
+	CodeNotAllowed     = -32001 /* mpic.command opcode not enabled */
+)
+
+const maxBody = 1 << 20 /* HTTP request size limit */
+
+// Error is a JSON-RPC error object
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// CommandParams are the parameters of mpic.command
+type CommandParams struct {
+	Dest byte   `json:"dest"`
+	Cmd  byte   `json:"cmd"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// Server executes JSON-RPC requests on one Device, serializing access
+type Server struct {
+	mu      sync.Mutex
+	dev     *mpic.Device
+	allow   map[byte]bool /* opcodes served by mpic.command */
+	methods map[string]func(json.RawMessage) (interface{}, error)
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithCommands option enables mpic.command for the given opcodes, other
+// opcodes are refused with CodeNotAllowed. Without it mpic.command is
+// not served.
+func WithCommands(opcodes ...byte) Option {
+	return func(s *Server) {
+		for _, op := range opcodes {
+			s.allow[op] = true
+		}
+	}
+}
+
+// NewServer function returns a JSON-RPC server for dev
+func NewServer(dev *mpic.Device, opts ...Option) *Server {
+	s := &Server{dev: dev, allow: make(map[byte]bool)}
+	s.methods = map[string]func(json.RawMessage) (interface{}, error){
+		"mpic.version":      s.version,
+		"mpic.negotiate":    s.negotiate,
+		"mpic.capabilities": s.capabilities,
+		"mpic.commands":     s.commands,
+		"mpic.health":       s.health,
+		"mpic.stats":        s.stats,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.allow) != 0 {
+		s.methods["mpic.command"] = s.command
+	}
+	return s
+}
+
+// ServeHTTP function handles one request or batch per POST body
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		code := http.StatusBadRequest
+		var me *http.MaxBytesError
+		if errors.As(err, &me) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	out := s.Handle(body)
+	if out == nil { /* notifications only */
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+// ServeConn function reads consecutive requests or batches from rw and
+// writes one response line for each until rw returns an error
+func (s *Server) ServeConn(rw io.ReadWriter) error {
+	dec := json.NewDecoder(rw)
+	for {
+		var msg json.RawMessage
+		if err := dec.Decode(&msg); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				out, _ := json.Marshal(errorResponse(nil, CodeParseError, err.Error()))
+				rw.Write(append(out, '\n'))
+			}
+			return err
+		}
+		if out := s.Handle(msg); out != nil {
+			if _, err := rw.Write(append(out, '\n')); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Handle function executes one encoded request or batch and returns the
+// encoded response, nil when there is nothing to answer
+func (s *Server) Handle(msg []byte) []byte {
+	msg = bytes.TrimSpace(msg)
+	var out interface{}
+	if len(msg) > 0 && msg[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(msg, &batch); err != nil {
+			out = errorResponse(nil, CodeParseError, err.Error())
+		} else if len(batch) == 0 {
+			out = errorResponse(nil, CodeInvalidRequest, "empty batch")
+		} else {
+			var resps []*response
+			for _, m := range batch {
+				if r := s.handleOne(m); r != nil {
+					resps = append(resps, r)
+				}
+			}
+			if len(resps) == 0 {
+				return nil
+			}
+			out = resps
+		}
+	} else {
+		r := s.handleOne(msg)
+		if r == nil {
+			return nil
+		}
+		out = r
+	}
+	b, _ := json.Marshal(out)
+	return b
+}
+
+func (s *Server) handleOne(msg json.RawMessage) *response {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return errorResponse(nil, CodeParseError, err.Error())
+		}
+		return errorResponse(nil, CodeInvalidRequest, err.Error())
+	}
+	if req.Version != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, CodeInvalidRequest, "invalid request")
+	}
+	notify := len(req.ID) == 0
+	f, ok := s.methods[req.Method]
+	if !ok {
+		if notify {
+			return nil
+		}
+		return errorResponse(req.ID, CodeMethodNotFound, "method not found")
+	}
+	s.mu.Lock()
+	res, err := f(req.Params)
+	s.mu.Unlock()
+	if notify {
+		return nil
+	}
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			return &response{Version: "2.0", Error: e, ID: req.ID}
+		}
+		return errorResponse(req.ID, CodeDeviceError, err.Error())
+	}
+	return &response{Version: "2.0", Result: res, ID: req.ID}
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) *response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &response{Version: "2.0", Error: &Error{Code: code, Message: msg}, ID: id}
+}
+
+func (s *Server) version(json.RawMessage) (interface{}, error) {
+	iver, irls, err := s.dev.GetVersion()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"version": iver, "release": irls}, nil
+}
+
+func (s *Server) negotiate(json.RawMessage) (interface{}, error) {
+	if err := s.dev.Negotiate(); err != nil {
+		return nil, err
+	}
+	return s.dev.Capabilities(), nil
+}
+
+func (s *Server) capabilities(json.RawMessage) (interface{}, error) {
+	return s.dev.Capabilities(), nil
+}
+
+func (s *Server) command(params json.RawMessage) (interface{}, error) {
+	var p CommandParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	if !s.allow[p.Cmd] {
+		return nil, &Error{Code: CodeNotAllowed, Message: fmt.Sprintf("command 0x%02x not allowed", p.Cmd)}
+	}
+	data, err := s.dev.Command(p.Dest, p.Cmd, p.Data)
+	if err == mpic.ErrCommandData {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{"data": data}, nil
+}
+
+func (s *Server) commands(json.RawMessage) (interface{}, error) {
+	return s.dev.ListCommands(), nil
+}
+
+func (s *Server) health(json.RawMessage) (interface{}, error) {
+	return s.dev.HealthCheck(context.Background())
+}
+
+func (s *Server) stats(json.RawMessage) (interface{}, error) {
+	return s.dev.Stats(), nil
+}
@@ -0,0 +1,78 @@
+package mpicrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/richardnwinder/mpic"
+	"github.com/richardnwinder/mpic/transport"
+)
+
+func newServer(t *testing.T, opts ...Option) *Server {
+	dev, err := mpic.OpenTransport(transport.NewSimulator(2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(dev.Close)
+	return NewServer(dev, opts...)
+}
+
+func TestHandle(t *testing.T) {
+	s := newServer(t, WithCommands(0x93))
+	for _, tc := range []struct {
+		name string
+		in   string
+		want string /* "" for no response */
+	}{
+		{"single", `{"jsonrpc":"2.0","method":"mpic.version","id":1}`,
+			`{"jsonrpc":"2.0","result":{"release":1,"version":2},"id":1}`},
+		{"notification", `{"jsonrpc":"2.0","method":"mpic.version"}`, ""},
+		{"parse error", `{"jsonrpc":`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"unexpected end of JSON input"},"id":null}`},
+		{"empty batch", `[]`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"},"id":null}`},
+		{"notification batch", `[{"jsonrpc":"2.0","method":"mpic.version"},{"jsonrpc":"2.0","method":"nope"}]`, ""},
+		{"parse error in batch", `[{"jsonrpc":"2.0","method":"mpic.version","id":1},{"jsonrpc"`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"unexpected end of JSON input"},"id":null}`},
+		{"invalid member", `[1,{"jsonrpc":"2.0","method":"mpic.version","id":2}]`,
+			`[{"jsonrpc":"2.0","error":{"code":-32600,"message":"json: cannot unmarshal number into Go value of type mpicrpc.request"},"id":null},` +
+				`{"jsonrpc":"2.0","result":{"release":1,"version":2},"id":2}]`},
+		{"mixed batch", `[{"jsonrpc":"2.0","method":"mpic.version"},{"jsonrpc":"2.0","method":"nope","id":"a"}]`,
+			`[{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found"},"id":"a"}]`},
+		{"allowed command", `{"jsonrpc":"2.0","method":"mpic.command","params":{"dest":4,"cmd":147},"id":3}`,
+			`{"jsonrpc":"2.0","result":{"data":"AgE="},"id":3}`},
+		{"refused command", `{"jsonrpc":"2.0","method":"mpic.command","params":{"dest":4,"cmd":19},"id":4}`,
+			`{"jsonrpc":"2.0","error":{"code":-32001,"message":"command 0x13 not allowed"},"id":4}`},
+	} {
+		got := string(s.Handle([]byte(tc.in)))
+		if got != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCommandDisabled(t *testing.T) {
+	out := newServer(t).Handle([]byte(`{"jsonrpc":"2.0","method":"mpic.command","params":{"dest":4,"cmd":147},"id":1}`))
+	var r response
+	if err := json.Unmarshal(out, &r); err != nil || r.Error == nil || r.Error.Code != CodeMethodNotFound {
+		t.Fatalf("%s", out)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	s := newServer(t)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","method":"mpic.version"}`)))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("notification: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(make([]byte, maxBody+1))))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body: %d", w.Code)
+	}
+}
@@ -0,0 +1,158 @@
+// Package mqttbridge connects an mpic Device to an MQTT broker for factory
+// floor deployments. It works with any MQTT client library through the
+// Client interface. Topics, below a configurable prefix:
+//
+//	<prefix>/events       hotplug events (published, JSON)
+//	<prefix>/health       health report every Interval (published, JSON)
+//	<prefix>/jobs         job requests (subscribed, JSON Job)
+//	<prefix>/jobs/result  job results (published, JSON Result)
+//
+// Supported job types are "health", "version" and "command". Command jobs
+// send raw device commands and are refused unless their opcode is listed
+// in Bridge.Commands, which is empty by default. There are
+// no encode or EHT jobs since package mpic does not implement them yet.
+package mqttbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/richardnwinder/mpic"
+)
+
+// Client is the part of an MQTT client used by the bridge
+type Client interface {
+	Publish(topic string, payload []byte) error
+	Subscribe(topic string, handler func(topic string, payload []byte)) error
+}
+
+// Job is a request received on <prefix>/jobs
+type Job struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Dest byte   `json:"dest,omitempty"` /* command jobs */
+	Cmd  byte   `json:"cmd,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// Result is published on <prefix>/jobs/result for each Job
+type Result struct {
+	ID     string      `json:"id"`
+	OK     bool        `json:"ok"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Bridge publishes device state and runs jobs on one Device
+type Bridge struct {
+	Prefix   string        /* topic prefix, default "mpic" */
+	Interval time.Duration /* health publish period, default 30s, <0 disables */
+	Logger   *log.Logger   /* Watch failures, nil for the standard logger */
+	Commands []byte        /* opcodes allowed in command jobs, none by default */
+
+	mu     sync.Mutex /* serializes device access */
+	client Client
+	dev    *mpic.Device
+}
+
+// New function returns a bridge between client and dev
+func New(client Client, dev *mpic.Device) *Bridge {
+	return &Bridge{Prefix: "mpic", Interval: 30 * time.Second, client: client, dev: dev}
+}
+
+// Run function subscribes to jobs and publishes events and health until
+// ctx ends. Hotplug events are skipped where Watch is not supported or
+// fails, e.g. without netlink access in a container; failures are logged.
+func (b *Bridge) Run(ctx context.Context) error {
+	if err := b.client.Subscribe(b.Prefix+"/jobs", b.handleJob); err != nil {
+		return err
+	}
+	events, err := mpic.Watch(ctx)
+	if err != nil && !errors.Is(err, mpic.ErrNotSupported) {
+		b.logf("mqttbridge: no hotplug events: %v", err)
+	}
+	var tick <-chan time.Time
+	if b.Interval > 0 {
+		t := time.NewTicker(b.Interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			b.publish("/events", map[string]interface{}{"type": ev.Type.String(), "device": ev.Device})
+		case <-tick:
+			b.mu.Lock()
+			rep, _ := b.dev.HealthCheck(ctx)
+			b.mu.Unlock()
+			b.publish("/health", rep)
+		}
+	}
+}
+
+func (b *Bridge) logf(format string, args ...interface{}) {
+	if b.Logger != nil {
+		b.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func (b *Bridge) publish(sub string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(b.Prefix+sub, data)
+}
+
+func (b *Bridge) handleJob(topic string, payload []byte) {
+	var job Job
+	if err := json.Unmarshal(payload, &job); err != nil {
+		b.publish("/jobs/result", Result{Error: err.Error()})
+		return
+	}
+	res, err := b.runJob(job)
+	r := Result{ID: job.ID, OK: err == nil, Result: res}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	b.publish("/jobs/result", r)
+}
+
+func (b *Bridge) runJob(job Job) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch job.Type {
+	case "health":
+		return b.dev.HealthCheck(context.Background())
+	case "version":
+		iver, irls, err := b.dev.GetVersion()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int{"version": iver, "release": irls}, nil
+	case "command":
+		if !bytes.Contains(b.Commands, []byte{job.Cmd}) {
+			return nil, fmt.Errorf("command 0x%02x not allowed", job.Cmd)
+		}
+		data, err := b.dev.Command(job.Dest, job.Cmd, job.Data)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"data": data}, nil
+	}
+	return nil, fmt.Errorf("unsupported job type %q", job.Type)
+}
@@ -0,0 +1,78 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/richardnwinder/mpic"
+	"github.com/richardnwinder/mpic/transport"
+)
+
+type fakeClient struct {
+	handler   func(topic string, payload []byte)
+	published map[string][][]byte
+}
+
+func (c *fakeClient) Publish(topic string, payload []byte) error {
+	c.published[topic] = append(c.published[topic], payload)
+	return nil
+}
+
+func (c *fakeClient) Subscribe(topic string, handler func(topic string, payload []byte)) error {
+	c.handler = handler
+	return nil
+}
+
+func TestJobs(t *testing.T) {
+	dev, err := mpic.OpenTransport(transport.NewSimulator(2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+	c := &fakeClient{published: make(map[string][][]byte)}
+	b := New(c, dev)
+	b.Commands = []byte{0x93}
+	for _, tc := range []struct {
+		name    string
+		payload string
+		ok      bool
+		result  string
+		err     string
+	}{
+		{"version", `{"id":"1","type":"version"}`, true, `{"release":1,"version":2}`, ""},
+		{"health", `{"id":"2","type":"health"}`, true, "", ""},
+		{"command", `{"id":"3","type":"command","dest":4,"cmd":147}`, true, `{"data":"AgE="}`, ""},
+		{"refused command", `{"id":"4","type":"command","dest":4,"cmd":19}`, false, "", "command 0x13 not allowed"},
+		{"unknown type", `{"id":"5","type":"encode"}`, false, "", `unsupported job type "encode"`},
+		{"bad json", `{"id":`, false, "", "unexpected end of JSON input"},
+	} {
+		b.handleJob("mpic/jobs", []byte(tc.payload))
+		results := c.published["mpic/jobs/result"]
+		if len(results) == 0 {
+			t.Fatalf("%s: no result", tc.name)
+		}
+		var r struct {
+			OK     bool            `json:"ok"`
+			Result json.RawMessage `json:"result"`
+			Error  string          `json:"error"`
+		}
+		if err := json.Unmarshal(results[len(results)-1], &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.OK != tc.ok || r.Error != tc.err || (tc.result != "" && string(r.Result) != tc.result) {
+			t.Errorf("%s: %s", tc.name, results[len(results)-1])
+		}
+	}
+}
+
+func TestCommandsOffByDefault(t *testing.T) {
+	dev, err := mpic.OpenTransport(transport.NewSimulator(2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+	b := New(&fakeClient{}, dev)
+	if _, err := b.runJob(Job{Type: "command", Dest: 4, Cmd: 0x93}); err == nil {
+		t.Fatal("command job ran without an allowlist")
+	}
+}
@@ -0,0 +1,43 @@
+package mpic
+
+import "time"
+
+// PowerState describes how the device is powered and connected. The USB
+// fields come from the OS and are zero when it does not report them.
+type PowerState struct {
+	SelfPowered   bool      `json:"self_powered"`   /* bmAttributes bit 6, bus powered if false */
+	RemoteWakeup  bool      `json:"remote_wakeup"`  /* bmAttributes bit 5 */
+	MaxPower      string    `json:"max_power"`      /* bMaxPower, e.g. "100mA" */
+	Configured    bool      `json:"configured"`     /* a configuration is selected */
+	Configuration int       `json:"configuration"`  /* bConfigurationValue */
+	Speed         string    `json:"speed"`          /* negotiated speed in Mbit/s */
+	Opened        time.Time `json:"opened"`         /* Open time */
+	LastReconnect time.Time `json:"last_reconnect"` /* zero if never reconnected */
+	Reconnects    int       `json:"reconnects"`     /* successful Reconnect calls */
+}
+
+// PowerState function returns the power and connection state of the
+// device, useful to spot dropouts caused by marginal hubs. The connection
+// times are always set; the error reports why USB fields are missing.
+// USB fields are only known for devices opened by serial through Open or
+// OpenSerial, ErrNotSupported is returned for OpenTransport devices.
+func (u *Device) PowerState() (PowerState, error) {
+	ps := PowerState{
+		Opened:        u.opened,
+		LastReconnect: u.reconnected,
+		Reconnects:    u.reconnects,
+	}
+	if u.serial == "" { /* OpenTransport, or a USB device without serial */
+		return ps, ErrNotSupported
+	}
+	devs, err := List()
+	if err != nil {
+		return ps, err
+	}
+	for _, d := range devs {
+		if d.Serial == u.serial {
+			return ps, usbPower(d, &ps)
+		}
+	}
+	return ps, ErrNoDevice
+}
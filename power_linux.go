@@ -0,0 +1,24 @@
+//go:build linux
+
+package mpic
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// usbPower fills ps from the sysfs attributes of d
+func usbPower(d DeviceInfo, ps *PowerState) error {
+	dir := filepath.Join(sysUsbDevices, d.Path)
+	attr := sysfsHex(dir, "bmAttributes")
+	if attr < 0 {
+		return ErrNoDevice /* unplugged since List */
+	}
+	ps.SelfPowered = attr&0x40 != 0
+	ps.RemoteWakeup = attr&0x20 != 0
+	ps.MaxPower = strings.TrimSpace(sysfsString(dir, "bMaxPower"))
+	ps.Configuration = sysfsInt(dir, "bConfigurationValue")
+	ps.Configured = ps.Configuration != 0
+	ps.Speed = d.Speed
+	return nil
+}
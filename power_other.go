@@ -0,0 +1,7 @@
+//go:build !linux
+
+package mpic
+
+func usbPower(d DeviceInfo, ps *PowerState) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,60 @@
+package mpic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Preflight causes, matched with errors.Is on the error returned by Preflight
+var (
+	ErrNoDevice         = errors.New("No mpic device attached")
+	ErrPermission       = errors.New("No read/write access to the device node")
+	ErrInterfaceClaimed = errors.New("Interface already claimed by another process")
+	ErrKernelDriver     = errors.New("Kernel driver bound to the interface")
+)
+
+// PreflightIssue is one access problem found by Preflight
+type PreflightIssue struct {
+	Device DeviceInfo
+	Cause  error  /* one of the Preflight causes */
+	Detail string /* what was observed */
+	Fix    string /* suggested action */
+}
+
+func (i PreflightIssue) String() string {
+	s := fmt.Sprintf("%v (%s); %s", i.Cause, i.Detail, i.Fix)
+	if i.Device.Path != "" {
+		s = i.Device.Path + ": " + s
+	}
+	return s
+}
+
+// PreflightError lists the issues found by Preflight
+type PreflightError struct {
+	Issues []PreflightIssue
+}
+
+func (e *PreflightError) Error() string {
+	s := make([]string, len(e.Issues))
+	for i, is := range e.Issues {
+		s[i] = is.String()
+	}
+	return "mpic preflight: " + strings.Join(s, "; ")
+}
+
+// Unwrap function returns the causes, for errors.Is
+func (e *PreflightError) Unwrap() []error {
+	errs := make([]error, len(e.Issues))
+	for i, is := range e.Issues {
+		errs[i] = is.Cause
+	}
+	return errs
+}
+
+// Preflight function checks that an attached device can be opened and
+// its interface 0 claimed by this process. It returns nil, a
+// *PreflightError describing each problem, or ErrNotSupported.
+func Preflight() error {
+	return preflight()
+}
@@ -0,0 +1,66 @@
+//go:build linux
+
+package mpic
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+func preflight() error {
+	devs, err := List()
+	if err != nil {
+		return err
+	}
+	if len(devs) == 0 {
+		return &PreflightError{Issues: []PreflightIssue{{
+			Cause:  ErrNoDevice,
+			Detail: fmt.Sprintf("no %04x:%04x device in %s", mp42Vid, mp42Pid, sysUsbDevices),
+			Fix:    "check the cable and hub, then lsusb",
+		}}}
+	}
+	var issues []PreflightIssue
+	for _, d := range devs {
+		node := d.devNode()
+		if err := syscall.Access(node, 0x6 /* R_OK|W_OK */); err != nil {
+			issues = append(issues, PreflightIssue{
+				Device: d,
+				Cause:  ErrPermission,
+				Detail: fmt.Sprintf("%s: %v", node, err),
+				Fix:    "install the rule from UdevRule (mpic udev-rule) and replug, or add the user to its group",
+			})
+		}
+		/* interface 0 of configuration 1 */
+		link, err := os.Readlink(filepath.Join(sysUsbDevices, d.Path+":1.0", "driver"))
+		if err != nil {
+			continue /* no driver bound */
+		}
+		switch drv := filepath.Base(link); drv {
+		case "usbfs": /* claimed through usbfs (libusb) */
+			issues = append(issues, PreflightIssue{
+				Device: d,
+				Cause:  ErrInterfaceClaimed,
+				Detail: "interface 0 bound to usbfs",
+				Fix:    "close the other program using the device",
+			})
+		default:
+			issues = append(issues, PreflightIssue{
+				Device: d,
+				Cause:  ErrKernelDriver,
+				Detail: "interface 0 bound to " + drv,
+				Fix:    fmt.Sprintf("unbind it: echo %s:1.0 > /sys/bus/usb/drivers/%s/unbind", d.Path, drv),
+			})
+		}
+	}
+	if len(issues) != 0 {
+		return &PreflightError{Issues: issues}
+	}
+	return nil
+}
+
+// devNode returns the usbfs node of the device
+func (d DeviceInfo) devNode() string {
+	return fmt.Sprintf("/dev/bus/usb/%03d/%03d", d.Bus, d.Address)
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package mpic
+
+func preflight() error {
+	return ErrNotSupported
+}
@@ -0,0 +1,29 @@
+package mpic
+
+// Progress receives progress reports from multi-step operations. stage
+// names the operation ("health", "diagnostics"), current counts the
+// finished steps of total and message describes the last one.
+type Progress interface {
+	Progress(stage string, current int64, total int64, message string)
+}
+
+// ProgressFunc adapts a function to the Progress interface
+type ProgressFunc func(stage string, current int64, total int64, message string)
+
+// Progress function calls f
+func (f ProgressFunc) Progress(stage string, current int64, total int64, message string) {
+	f(stage, current, total, message)
+}
+
+// WithProgress option sends progress reports of Device operations to p
+func WithProgress(p Progress) Option {
+	return func(u *Device) {
+		u.prog = p
+	}
+}
+
+func (u *Device) progress(stage string, current int64, total int64, message string) {
+	if u.prog != nil {
+		u.prog.Progress(stage, current, total, message)
+	}
+}
@@ -0,0 +1,34 @@
+package protocol
+
+const (
+	DestMp4x   = 4    /* command destination, 4 - mp4x */
+	CmdVersion = 0x93 /* ICMD: return version and release */
+)
+
+// CommandInfo describes one firmware command
+type CommandInfo struct {
+	Name       string `json:"name"`
+	Dest       byte   `json:"dest"`
+	Opcode     byte   `json:"opcode"`
+	In         bool   `json:"in"`          /* ICMD (b7 = 1), IN data follows INSYNC */
+	MinData    int    `json:"min_data"`    /* command data bytes */
+	MaxData    int    `json:"max_data"`    /* at most 60 */
+	Response   int    `json:"response"`    /* IN data bytes, -1 if variable */
+	MinVersion int    `json:"min_version"` /* 10*ver + rls */
+	MaxVersion int    `json:"max_version"` /* 0 if not limited */
+}
+
+// Supports function tells whether version verl (10*ver + rls) has the command
+func (c CommandInfo) Supports(verl int) bool {
+	return verl >= c.MinVersion && (c.MaxVersion == 0 || verl <= c.MaxVersion)
+}
+
+/* mp4x commands known to this package */
+var mp4xCommands = []CommandInfo{
+	{Name: "version", Dest: DestMp4x, Opcode: CmdVersion, In: true, Response: 2, MinVersion: 12},
+}
+
+// Commands function returns the known mp4x commands
+func Commands() []CommandInfo {
+	return append([]CommandInfo(nil), mp4xCommands...)
+}
@@ -0,0 +1,86 @@
+// Package protocol implements the mp4x EP1 command protocol without any
+// USB access: command framing, the INSYNC handshake and response parsing.
+// It is shared by package mpic, the simulator in package transport and
+// tools that decode captured traffic.
+//
+// A command packet is sent on EP1 OUT as
+//
+//	dest cmd ccnt ccb[ccnt]
+//
+// with ccnt at most 60. IN commands (cmd b7 = 1) are
+// answered by one INSYNC byte (0xff) followed by one IN packet on EP1 IN.
+package protocol
+
+import "errors"
+
+const (
+	VID = 0x04d8 /* mp42 VID (Mchip) */
+	PID = 0xfca7 /* mp42 PID (MDS license) */
+
+	EP1In  = 0x00000081
+	EP1Out = 0x00000001
+
+	MaxPacketSize = 64   /* max one packet size */
+	MaxCmdData    = 0x3c /* max command data bytes (ccnt_max) */
+	HeaderSize    = 3    /* dest, cmd, ccnt */
+	Insync        = 0xff /* first IN byte after an IN command */
+)
+
+var (
+	// ErrCommandData is returned when command data exceeds 60 bytes
+	ErrCommandData = errors.New("Command data too long")
+
+	// ErrInsync is returned when an IN command is not answered by INSYNC
+	ErrInsync = errors.New("USB insync error")
+
+	// ErrBadResponse is returned for IN data of unexpected length
+	ErrBadResponse = errors.New("Bad Response")
+
+	// ErrFrame is returned by DecodeCommand for malformed packets
+	ErrFrame = errors.New("Bad command packet")
+)
+
+// IsIn function tells whether cmd is an IN command (b7 = 1)
+func IsIn(cmd byte) bool {
+	return (cmd & 0x80) != 0
+}
+
+// EncodeCommand function builds the packet of cmd with data to dest in
+// dst and returns its length. dst must hold HeaderSize + len(data) bytes.
+func EncodeCommand(dst []byte, dest byte, cmd byte, data []byte) (int, error) {
+	if len(data) > MaxCmdData {
+		return 0, ErrCommandData
+	}
+	if len(dst) < HeaderSize+len(data) {
+		return 0, errors.New("Command buffer too short")
+	}
+	dst[0] = dest
+	dst[1] = cmd
+	dst[2] = byte(len(data))
+	return HeaderSize + copy(dst[HeaderSize:], data), nil
+}
+
+// DecodeCommand function splits a command packet, data aliases pkt
+func DecodeCommand(pkt []byte) (dest byte, cmd byte, data []byte, err error) {
+	if len(pkt) < HeaderSize || len(pkt) != HeaderSize+int(pkt[2]) || int(pkt[2]) > MaxCmdData {
+		return 0, 0, nil, ErrFrame
+	}
+	return pkt[0], pkt[1], pkt[HeaderSize:], nil
+}
+
+// CheckInsync function checks the n byte INSYNC transfer in b
+func CheckInsync(n int, b []byte) error {
+	if n != 1 || b[0] != Insync {
+		return ErrInsync
+	}
+	return nil
+}
+
+// ParseVersion function returns version and release from the IN data of
+// the version command
+func ParseVersion(data []byte) (int, int, error) {
+	if len(data) != 2 {
+		return 0, 0, ErrBadResponse
+	}
+	return int(data[0]), int(data[1]), nil
+}
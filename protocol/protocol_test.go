@@ -0,0 +1,64 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, data := range [][]byte{nil, {1}, bytes.Repeat([]byte{0xa5}, MaxCmdData)} {
+		buf := make([]byte, MaxPacketSize)
+		n, err := EncodeCommand(buf, DestMp4x, CmdVersion, data)
+		if err != nil || n != HeaderSize+len(data) {
+			t.Fatalf("encode %d bytes: %d %v", len(data), n, err)
+		}
+		if buf[0] != DestMp4x || buf[1] != CmdVersion || int(buf[2]) != len(data) {
+			t.Fatalf("header % x", buf[:HeaderSize])
+		}
+		dest, cmd, got, err := DecodeCommand(buf[:n])
+		if err != nil || dest != DestMp4x || cmd != CmdVersion || !bytes.Equal(got, data) {
+			t.Fatalf("decode %d bytes: %d %x % x %v", len(data), dest, cmd, got, err)
+		}
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	if _, err := EncodeCommand(make([]byte, 100), DestMp4x, 0x13, make([]byte, MaxCmdData+1)); err != ErrCommandData {
+		t.Errorf("long data: %v", err)
+	}
+	if _, err := EncodeCommand(make([]byte, 4), DestMp4x, 0x13, []byte{1, 2}); err == nil {
+		t.Error("short buffer accepted")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, pkt := range [][]byte{
+		nil,
+		{4, 0x93},
+		{4, 0x93, 1},    /* data missing */
+		{4, 0x93, 0, 7}, /* trailing byte */
+		append([]byte{4, 0x13, 61}, make([]byte, 61)...),
+	} {
+		if _, _, _, err := DecodeCommand(pkt); err != ErrFrame {
+			t.Errorf("% x: %v", pkt, err)
+		}
+	}
+}
+
+func TestInsyncVersion(t *testing.T) {
+	if CheckInsync(1, []byte{Insync}) != nil {
+		t.Error("INSYNC rejected")
+	}
+	if CheckInsync(1, []byte{0}) != ErrInsync || CheckInsync(2, []byte{Insync, 0}) != ErrInsync {
+		t.Error("bad INSYNC accepted")
+	}
+	if v, r, err := ParseVersion([]byte{2, 1}); v != 2 || r != 1 || err != nil {
+		t.Errorf("version %d.%d %v", v, r, err)
+	}
+	if _, _, err := ParseVersion([]byte{2}); err != ErrBadResponse {
+		t.Errorf("short version: %v", err)
+	}
+	if IsIn(0x13) || !IsIn(CmdVersion) {
+		t.Error("IsIn")
+	}
+}
@@ -0,0 +1,344 @@
+// Package remote tunnels the mpic Transport over TCP, so a device attached
+// to one machine can be driven by the mpic package on another:
+//
+//	/* machine A, device attached */
+//	t, _ := usb.OpenVidPid(0x04d8, 0xfca7)
+//	l, _ := net.Listen("tcp", "127.0.0.1:7342")
+//	remote.Serve(l, t, remote.WithSecret(secret))
+//
+//	/* machine B, e.g. through ssh -L 7342:127.0.0.1:7342 machine-a */
+//	t, _ := remote.Dial("127.0.0.1:7342", remote.WithSecret(secret))
+//	dev, _ := mpic.OpenTransport(t)
+//
+// Each transport call is one request/response exchange. Only one client
+// is served at a time; the device stays open on the server when a client
+// disconnects. A client idle for longer than the idle timeout is
+// disconnected so it can not hold the device forever.
+//
+// Connections start with a challenge: the server sends a random nonce and
+// the client answers its HMAC-SHA256 keyed by the shared secret (empty
+// without WithSecret), then the server replies one byte, 1 to accept or
+// 0 to reject before closing. The tunnel is not encrypted, listen on localhost
+// or a trusted network only.
+package remote
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/richardnwinder/mpic/transport"
+)
+
+const (
+	opClose   = 1
+	opClaim   = 2
+	opRelease = 3
+	opBulk    = 4
+
+	maxPayload = 1 << 20 /* larger than any EP2 transfer (16k) */
+	nonceSize  = 16
+	authAccept = 1
+	authReject = 0
+
+	ioSlack     = 5 * time.Second  /* network time on top of the transfer timeout */
+	idleTimeout = 30 * time.Second /* default, see WithIdleTimeout */
+)
+
+// ErrPayload is returned for frames exceeding the maximum payload size
+var ErrPayload = errors.New("remote payload too large")
+
+// ErrOutLength is returned for OUT transfers whose length differs from
+// the payload size
+var ErrOutLength = errors.New("remote OUT length does not match payload")
+
+// ErrAuth is returned by Dial when the server rejects the secret
+var ErrAuth = errors.New("remote authentication failed")
+
+type config struct {
+	secret []byte
+	idle   time.Duration
+}
+
+// Option configures Serve or Dial
+type Option func(*config)
+
+// WithSecret option sets the shared secret, it must be the same for
+// Serve and Dial
+func WithSecret(secret string) Option {
+	return func(c *config) {
+		c.secret = []byte(secret)
+	}
+}
+
+// WithIdleTimeout option sets how long Serve waits for the next request
+// of a client before disconnecting it, 30s by default
+func WithIdleTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.idle = d
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{idle: idleTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *config) mac(nonce []byte) []byte {
+	h := hmac.New(sha256.New, c.secret)
+	h.Write(nonce)
+	return h.Sum(nil)
+}
+
+// xferDeadline returns the deadline of a call with a timeout in ms
+func xferDeadline(timeout uint32) time.Time {
+	return time.Now().Add(time.Duration(timeout)*time.Millisecond + ioSlack)
+}
+
+/* request:  op u8, endpoint u32, length u32, timeout u32, payload (OUT only)
+ * response: n u32, payload (IN only), error string (empty on success)
+ * payloads and strings are prefixed with their u32 length */
+
+// Serve function accepts connections on l and executes their transport
+// calls on t, one client at a time, until l is closed
+func Serve(l net.Listener, t transport.Transport, opts ...Option) error {
+	cfg := newConfig(opts)
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		serveConn(c, t, cfg)
+	}
+}
+
+// authenticate runs the server side of the challenge
+func authenticate(c net.Conn, cfg *config) bool {
+	c.SetDeadline(time.Now().Add(ioSlack))
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return false
+	}
+	if _, err := c.Write(nonce); err != nil {
+		return false
+	}
+	mac := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(c, mac); err != nil {
+		return false
+	}
+	if !hmac.Equal(mac, cfg.mac(nonce)) {
+		c.Write([]byte{authReject})
+		return false
+	}
+	_, err := c.Write([]byte{authAccept})
+	return err == nil
+}
+
+func serveConn(c net.Conn, t transport.Transport, cfg *config) {
+	defer c.Close()
+	if !authenticate(c, cfg) {
+		return
+	}
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	var hdr [13]byte
+	for {
+		c.SetDeadline(time.Now().Add(cfg.idle))
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return
+		}
+		op := hdr[0]
+		endpoint := binary.BigEndian.Uint32(hdr[1:])
+		length := binary.BigEndian.Uint32(hdr[5:])
+		timeout := binary.BigEndian.Uint32(hdr[9:])
+		c.SetDeadline(xferDeadline(timeout))
+		var n int
+		var in []byte
+		var err error
+		switch op {
+		case opClose:
+			return
+		case opClaim:
+			err = t.ClaimInterface(endpoint)
+		case opRelease:
+			err = t.ReleaseInterface(endpoint)
+		case opBulk:
+			var data []byte
+			if (endpoint & 0x80) == 0 {
+				if data, err = readBlock(r); err != nil {
+					return
+				}
+				if int(length) != len(data) {
+					err = ErrOutLength
+					break
+				}
+			} else {
+				if length > maxPayload {
+					return
+				}
+				data = make([]byte, length)
+			}
+			n, in, err = t.BulkTransfer(endpoint, length, timeout, data)
+			if (endpoint & 0x80) == 0 {
+				in = nil
+			} else if n <= len(data) {
+				in = data[:n]
+			}
+		default:
+			return
+		}
+		binary.Write(w, binary.BigEndian, uint32(n))
+		writeBlock(w, in)
+		msg := ""
+		if err != nil {
+			msg = err.Error()
+		}
+		writeBlock(w, []byte(msg))
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+func readBlock(r io.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	if n > maxPayload {
+		return nil, ErrPayload
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+func writeBlock(w io.Writer, b []byte) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(b))); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// Transport is the client side of the tunnel, it implements transport.Transport
+type Transport struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+var _ transport.Transport = (*Transport)(nil)
+
+// Dial function connects to a Serve running at addr
+func Dial(addr string, opts ...Option) (*Transport, error) {
+	cfg := newConfig(opts)
+	c, err := net.DialTimeout("tcp", addr, ioSlack)
+	if err != nil {
+		return nil, err
+	}
+	c.SetDeadline(time.Now().Add(ioSlack))
+	nonce := make([]byte, nonceSize)
+	if _, err = io.ReadFull(c, nonce); err == nil {
+		_, err = c.Write(cfg.mac(nonce))
+	}
+	var reply [1]byte
+	if err == nil {
+		_, err = io.ReadFull(c, reply[:])
+	}
+	if err == nil && reply[0] != authAccept {
+		err = ErrAuth
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.SetDeadline(time.Time{})
+	return &Transport{conn: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}, nil
+}
+
+// call sends one request and reads its response
+func (t *Transport) call(op byte, endpoint uint32, length uint32, timeout uint32, out []byte, in []byte) (int, []byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var hdr [13]byte
+	hdr[0] = op
+	binary.BigEndian.PutUint32(hdr[1:], endpoint)
+	binary.BigEndian.PutUint32(hdr[5:], length)
+	binary.BigEndian.PutUint32(hdr[9:], timeout)
+	t.conn.SetDeadline(xferDeadline(timeout))
+	t.w.Write(hdr[:])
+	if out != nil {
+		writeBlock(t.w, out)
+	}
+	if err := t.w.Flush(); err != nil {
+		return 0, nil, err
+	}
+	var n uint32
+	if err := binary.Read(t.r, binary.BigEndian, &n); err != nil {
+		return 0, nil, err
+	}
+	data, err := readBlock(t.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	msg, err := readBlock(t.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(msg) != 0 {
+		return int(n), nil, errors.New(string(msg))
+	}
+	if in != nil {
+		c := copy(in, data) /* truncated to the caller's buffer */
+		return c, in[:c], nil
+	}
+	return int(n), nil, nil
+}
+
+// Close function ends the session and closes the connection
+func (t *Transport) Close() {
+	t.mu.Lock()
+	var hdr [13]byte
+	hdr[0] = opClose
+	t.conn.SetDeadline(time.Now().Add(ioSlack))
+	t.w.Write(hdr[:])
+	t.w.Flush()
+	t.conn.Close()
+	t.mu.Unlock()
+}
+
+// ClaimInterface function claims interface n on the remote device
+func (t *Transport) ClaimInterface(n uint32) error {
+	_, _, err := t.call(opClaim, n, 0, 0, nil, nil)
+	return err
+}
+
+// ReleaseInterface function releases interface n on the remote device
+func (t *Transport) ReleaseInterface(n uint32) error {
+	_, _, err := t.call(opRelease, n, 0, 0, nil, nil)
+	return err
+}
+
+// BulkTransfer function runs one bulk transfer on the remote device
+func (t *Transport) BulkTransfer(endpoint uint32, length uint32, timeout uint32, data []byte) (int, []byte, error) {
+	if (endpoint & 0x80) == 0 {
+		if int(length) > len(data) {
+			return 0, nil, io.ErrShortBuffer
+		}
+		n, _, err := t.call(opBulk, endpoint, length, timeout, data[:length], nil)
+		return n, data[:length], err
+	}
+	return t.call(opBulk, endpoint, length, timeout, nil, data)
+}
@@ -0,0 +1,114 @@
+package remote
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/richardnwinder/mpic/protocol"
+	"github.com/richardnwinder/mpic/transport"
+)
+
+func serve(t *testing.T, opts ...Option) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go Serve(l, transport.NewSimulator(2, 1), opts...)
+	return l.Addr().String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	c, err := Dial(serve(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	cmd := make([]byte, protocol.MaxPacketSize)
+	n, _ := protocol.EncodeCommand(cmd, protocol.DestMp4x, protocol.CmdVersion, nil)
+	if _, _, err := c.BulkTransfer(protocol.EP1Out, uint32(n), 100, cmd); err != nil {
+		t.Fatal(err)
+	}
+	in := make([]byte, protocol.MaxPacketSize)
+	n, data, err := c.BulkTransfer(protocol.EP1In, protocol.MaxPacketSize, 100, in)
+	if err != nil || n != 1 || data[0] != protocol.Insync {
+		t.Fatalf("insync: %d %x %v", n, data, err)
+	}
+	/* the 2 byte version answer truncated to a 1 byte buffer */
+	n, data, err = c.BulkTransfer(protocol.EP1In, protocol.MaxPacketSize, 100, in[:1])
+	if err != nil || n != 1 || len(data) != 1 || data[0] != 2 {
+		t.Fatalf("truncated: %d %x %v", n, data, err)
+	}
+}
+
+func TestOutLength(t *testing.T) {
+	c, err := Dial(serve(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, tc := range []struct {
+		length uint32
+		data   []byte
+	}{
+		{3, []byte{}},            /* empty OUT block */
+		{10, []byte{4, 0x13, 0}}, /* length past the payload */
+		{2, []byte{4, 0x13, 0}},  /* length short of the payload */
+	} {
+		_, _, err := c.call(opBulk, protocol.EP1Out, tc.length, 100, tc.data, nil)
+		if err == nil || err.Error() != ErrOutLength.Error() {
+			t.Errorf("length %d, %d bytes: %v", tc.length, len(tc.data), err)
+		}
+	}
+}
+
+func TestSecret(t *testing.T) {
+	addr := serve(t, WithSecret("s3"))
+	c, err := Dial(addr, WithSecret("s3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ClaimInterface(0); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if _, err = Dial(addr, WithSecret("wrong")); !errors.Is(err, ErrAuth) {
+		t.Fatalf("wrong secret: %v", err)
+	}
+	if _, err = Dial(addr); !errors.Is(err, ErrAuth) {
+		t.Fatalf("no secret: %v", err)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	addr := serve(t, WithIdleTimeout(50*time.Millisecond))
+	quiet, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer quiet.Close()
+	nonce := make([]byte, nonceSize)
+	io.ReadFull(quiet, nonce)
+	quiet.Write((&config{}).mac(nonce))
+	var reply [1]byte
+	if _, err := io.ReadFull(quiet, reply[:]); err != nil || reply[0] != authAccept {
+		t.Fatalf("handshake: %v %d", err, reply[0])
+	}
+	/* the quiet client is dropped and the next one is served */
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.ClaimInterface(0); err != nil {
+		t.Fatal(err)
+	}
+	var n uint32
+	if err := binary.Read(quiet, binary.BigEndian, &n); !errors.Is(err, io.EOF) {
+		t.Fatalf("quiet client still connected: %v", err)
+	}
+}
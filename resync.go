@@ -0,0 +1,33 @@
+package mpic
+
+import "errors"
+
+const (
+	drainTimeout = 10 /* ms, a stale IN packet is already queued */
+	drainMax     = 64 /* packets, bounds a device that keeps sending */
+)
+
+// ClearBuffers function recovers the command stream without a USB reset:
+// it drains stale EP1 IN packets left by an interrupted command, resets
+// the ob/ib/ocb/icb buffers and checks INSYNC with a version command.
+// EP2 is not drained since this package does not use it yet.
+func (u *Device) ClearBuffers() (err error) {
+	_, end := u.span("mpic.ClearBuffers")
+	defer func() { end(err) }()
+	for i := 0; ; i++ {
+		if i == drainMax {
+			return errors.New("USB EP1 IN drain overflow")
+		}
+		n, _, err := u.bulkTransfer(u.epIn, uint32(maxPacketSize), drainTimeout, u.icb.buf)
+		if err != nil || n == 0 {
+			break /* timed out, nothing left */
+		}
+	}
+	for _, b := range []*iobuf{&u.ob, &u.ib, &u.ocb, &u.icb} {
+		resetBuffer(b.buf, len(b.buf))
+		b.cnt = 0
+	}
+	/* a version command answers INSYNC then the version pair */
+	_, _, err = u.sepgGetVersion()
+	return err
+}
@@ -0,0 +1,90 @@
+package mpic
+
+import "time"
+
+// OpcodeStats holds the latency distribution of one command opcode
+type OpcodeStats struct {
+	Count   int             `json:"count"`
+	Errors  int             `json:"errors"`
+	Sum     time.Duration   `json:"sum"`
+	Min     time.Duration   `json:"min"`
+	Max     time.Duration   `json:"max"`
+	Bounds  []time.Duration `json:"bounds"`  /* bucket upper bounds */
+	Buckets []int           `json:"buckets"` /* counts per bucket, last one is +Inf */
+}
+
+// Mean function returns the average command latency
+func (s OpcodeStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(s.Count)
+}
+
+// Stats is a snapshot of the Device counters
+type Stats struct {
+	Commands int                  `json:"commands"`
+	Errors   map[string]int       `json:"errors"`
+	Opcodes  map[byte]OpcodeStats `json:"opcodes"`
+}
+
+type opcodeHist struct {
+	count   int
+	errors  int
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+	buckets []int
+}
+
+// add stores one latency sample, called with trace.mu held
+func (h *opcodeHist) add(d time.Duration, failed bool) {
+	if h.buckets == nil {
+		h.buckets = make([]int, len(latencyBuckets)+1)
+	}
+	if failed {
+		h.errors++
+		return
+	}
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	h.buckets[i]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Stats function returns command counters and per opcode latency
+// distributions collected since Open
+func (u *Device) Stats() Stats {
+	t := &u.trace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := Stats{
+		Errors:  make(map[string]int, len(t.errs)),
+		Opcodes: make(map[byte]OpcodeStats, len(t.hist)),
+	}
+	for k, v := range t.errs {
+		st.Errors[k] = v
+	}
+	for op, h := range t.hist {
+		st.Commands += h.count + h.errors
+		st.Opcodes[op] = OpcodeStats{
+			Count:   h.count,
+			Errors:  h.errors,
+			Sum:     h.sum,
+			Min:     h.min,
+			Max:     h.max,
+			Bounds:  append([]time.Duration(nil), latencyBuckets...),
+			Buckets: append([]int(nil), h.buckets...),
+		}
+	}
+	return st
+}
@@ -0,0 +1,106 @@
+package mpic
+
+import "time"
+
+const (
+	insyncTimeout = 3000 /* worst case INSYNC wait in ms */
+	cmdInTimeout  = 1000 /* worst case IN data wait in ms */
+	cmdOutTimeout = 1000 /* command send timeout in ms */
+
+	adaptSamples = 8 /* commands observed before the window shrinks */
+)
+
+type adaptiveTimeout struct {
+	on  bool
+	ops map[byte]*adaptWindow /* by opcode, commands differ widely */
+}
+
+type adaptWindow struct {
+	n    int
+	ewma time.Duration /* smoothed IN command round-trip time */
+}
+
+// WithAdaptiveTimeout option lets the INSYNC and IN wait window of each
+// opcode follow its observed latency. Successful reads return as soon as
+// data arrives either way, the only effect is faster failure detection
+// when a device stops answering. The window never drops below a per
+// version floor, never exceeds the fixed delay and falls back to the
+// fixed delay after any IN failure of that opcode.
+func WithAdaptiveTimeout() Option {
+	return func(u *Device) {
+		u.adapt.on = true
+	}
+}
+
+// WithTimeout option replaces the fixed worst case EP1 transfer timeouts
+// (1s to send, 3s for INSYNC, 1s for IN data) by d
+func WithTimeout(d time.Duration) Option {
+	return func(u *Device) {
+		u.tmo = uint32(d / time.Millisecond)
+	}
+}
+
+// fixedTimeout returns the worst case timeout in ms, def unless WithTimeout
+func (u *Device) fixedTimeout(def uint32) uint32 {
+	if u.tmo != 0 {
+		return u.tmo
+	}
+	return def
+}
+
+// adaptFloor returns the smallest IN wait for the negotiated version
+func (u *Device) adaptFloor() time.Duration {
+	switch {
+	case u.verl >= 20:
+		return 100 * time.Millisecond
+	case u.verl >= 13:
+		return 150 * time.Millisecond
+	default: /* v1.2 or not negotiated */
+		return 250 * time.Millisecond
+	}
+}
+
+// inTimeout returns the IN wait of cmd in ms, max being the fixed worst case
+func (u *Device) inTimeout(cmd byte, max uint32) uint32 {
+	max = u.fixedTimeout(max)
+	w := u.adapt.ops[cmd]
+	if !u.adapt.on || w == nil || w.n < adaptSamples {
+		return max
+	}
+	d := 4*w.ewma + 20*time.Millisecond
+	if floor := u.adaptFloor(); d < floor {
+		d = floor
+	}
+	ms := uint32(d / time.Millisecond)
+	if ms > max {
+		return max
+	}
+	return ms
+}
+
+// observe feeds one IN command result into the adaptive window of cmd
+func (u *Device) observe(cmd byte, d time.Duration, err error) {
+	a := &u.adapt
+	if !a.on {
+		return
+	}
+	w := a.ops[cmd]
+	if w == nil {
+		if a.ops == nil {
+			a.ops = make(map[byte]*adaptWindow)
+		}
+		w = &adaptWindow{}
+		a.ops[cmd] = w
+	}
+	if err != nil { /* grow back to the worst case until relearned */
+		w.n = 0
+		w.ewma = 0
+		return
+	}
+	if w.n == 0 {
+		w.ewma = d
+	} else {
+		w.ewma += (d - w.ewma) / 8
+	}
+	w.n++
+}
@@ -0,0 +1,62 @@
+package mpic
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/richardnwinder/mpic/protocol"
+)
+
+/* flakyTransport fails EP1 IN transfers while fail is set */
+type flakyTransport struct {
+	*Simulator
+	fail bool
+}
+
+func (f *flakyTransport) BulkTransfer(endpoint uint32, length uint32, timeout uint32, data []byte) (int, []byte, error) {
+	if f.fail && endpoint == protocol.EP1In {
+		return 0, nil, errors.New("IN failed")
+	}
+	return f.Simulator.BulkTransfer(endpoint, length, timeout, data)
+}
+
+func TestAdaptiveTimeout(t *testing.T) {
+	ft := &flakyTransport{Simulator: NewSimulator(2, 1)}
+	u, err := OpenTransport(ft, WithAdaptiveTimeout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	if err := u.Negotiate(); err != nil {
+		t.Fatal(err)
+	}
+	floor := uint32(u.adaptFloor().Milliseconds())
+	for i := 1; i < adaptSamples; i++ { /* Negotiate was the first sample */
+		if got := u.inTimeout(protocol.CmdVersion, cmdInTimeout); got != cmdInTimeout {
+			t.Fatalf("after %d commands timeout = %d, want %d", i, got, cmdInTimeout)
+		}
+		if _, _, err := u.GetVersion(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := u.inTimeout(protocol.CmdVersion, cmdInTimeout)
+	if got >= cmdInTimeout || got < floor {
+		t.Fatalf("after %d commands timeout = %d, want %d..%d", adaptSamples, got, floor, cmdInTimeout-1)
+	}
+	for i := 0; i < 2*adaptSamples; i++ {
+		if _, _, err := u.GetVersion(); err != nil {
+			t.Fatal(err)
+		}
+		if got := u.inTimeout(protocol.CmdVersion, cmdInTimeout); got < floor {
+			t.Fatalf("timeout = %d below floor %d", got, floor)
+		}
+	}
+
+	ft.fail = true
+	if _, _, err := u.GetVersion(); err == nil {
+		t.Fatal("GetVersion succeeded with IN failing")
+	}
+	if got := u.inTimeout(protocol.CmdVersion, cmdInTimeout); got != cmdInTimeout {
+		t.Errorf("after IN error timeout = %d, want %d", got, cmdInTimeout)
+	}
+}
@@ -0,0 +1,106 @@
+package mpic
+
+import (
+	"sync"
+	"time"
+)
+
+const traceSize = 64 /* number of commands kept in the trace ring */
+
+// TraceEntry describes one executed command
+type TraceEntry struct {
+	Time    time.Time     `json:"time"`
+	Cmd     byte          `json:"cmd"`
+	Out     int           `json:"out"` /* bytes sent on EP1 OUT */
+	In      int           `json:"in"`  /* bytes received on EP1 IN */
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type cmdTrace struct {
+	mu   sync.Mutex
+	ring [traceSize]TraceEntry
+	next int
+	full bool
+	errs map[string]int
+	hist map[byte]*opcodeHist
+}
+
+// record stores one finished command in the trace and reports it to metrics
+func (u *Device) record(cmd byte, out int, in int, d time.Duration, class string, err error) {
+	e := TraceEntry{Time: time.Now().Add(-d), Cmd: cmd, Out: out, In: in, Latency: d}
+	if (cmd&0x80) != 0 && err == nil {
+		e.In++ /* INSYNC byte */
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	t := &u.trace
+	t.mu.Lock()
+	t.ring[t.next] = e
+	t.next = (t.next + 1) % traceSize
+	if t.next == 0 {
+		t.full = true
+	}
+	if t.hist == nil {
+		t.hist = make(map[byte]*opcodeHist)
+	}
+	h := t.hist[cmd]
+	if h == nil {
+		h = &opcodeHist{}
+		t.hist[cmd] = h
+	}
+	h.add(d, err != nil)
+	t.mu.Unlock()
+
+	if u.metrics != nil {
+		u.metrics.Command(cmd)
+		if err == nil {
+			u.metrics.Bytes(e.Out, e.In)
+			u.metrics.Latency(cmd, d)
+		}
+	}
+	if err != nil {
+		u.recordError(class)
+	}
+	for _, f := range u.hooks.command {
+		f(u, e)
+	}
+}
+
+// recordError counts one error of class
+func (u *Device) recordError(class string) {
+	t := &u.trace
+	t.mu.Lock()
+	if t.errs == nil {
+		t.errs = make(map[string]int)
+	}
+	t.errs[class]++
+	t.mu.Unlock()
+	if u.metrics != nil {
+		u.metrics.Error(class)
+	}
+}
+
+// Trace function returns the most recent commands, oldest first
+func (u *Device) Trace() []TraceEntry {
+	t := &u.trace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.full {
+		return append([]TraceEntry(nil), t.ring[:t.next]...)
+	}
+	return append(append([]TraceEntry(nil), t.ring[t.next:]...), t.ring[:t.next]...)
+}
+
+// ErrorCounts function returns the number of errors seen per class
+func (u *Device) ErrorCounts() map[string]int {
+	t := &u.trace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := make(map[string]int, len(t.errs))
+	for k, v := range t.errs {
+		m[k] = v
+	}
+	return m
+}
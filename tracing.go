@@ -0,0 +1,75 @@
+package mpic
+
+import "context"
+
+// Tracer is the tracing hook used by Device. It maps directly onto an
+// OpenTelemetry trace.Tracer: Start wraps tracer.Start, SetAttribute wraps
+// span.SetAttributes(attribute.Int64(key, value)) and End records err (if
+// any) before calling span.End.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is one traced operation started by a Tracer
+type Span interface {
+	SetAttribute(key string, value int64)
+	End(err error)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value int64) {}
+func (noopSpan) End(err error)                        {}
+
+// WithTracer option attaches a tracing hook to the Device
+func WithTracer(t Tracer) Option {
+	return func(u *Device) {
+		u.tracer = t
+	}
+}
+
+// span starts a child of the span of the running operation. The returned
+// func ends it and makes the parent current again.
+func (u *Device) span(name string) (Span, func(error)) {
+	return u.spanCtx(u.tctx, name)
+}
+
+// spanCtx starts a span with parent ctx, used by operations taking a context
+func (u *Device) spanCtx(ctx context.Context, name string) (Span, func(error)) {
+	if u.tracer == nil {
+		return noopSpan{}, func(error) {}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	prev := u.tctx
+	sctx, s := u.tracer.Start(ctx, name)
+	u.tctx = sctx
+	return s, func(err error) {
+		s.End(err)
+		u.tctx = prev
+	}
+}
+
+// bulkTransfer runs one USB bulk transfer inside its own span
+func (u *Device) bulkTransfer(endpoint uint32, length uint32, timeout uint32, data []byte) (int, []byte, error) {
+	s, end := u.span("mpic.bulk_transfer")
+	s.SetAttribute("usb.endpoint", int64(endpoint))
+	s.SetAttribute("usb.length", int64(length))
+	if u.dev == nil {
+		end(ErrDeviceGone)
+		return 0, nil, ErrDeviceGone
+	}
+	n, odata, err := u.dev.BulkTransfer(endpoint, length, timeout, data)
+	s.SetAttribute("usb.transferred", int64(n))
+	end(err)
+	return n, odata, err
+}
+
+// opContext returns the span context of the running operation
+func (u *Device) opContext() context.Context {
+	if u.tctx == nil {
+		return context.Background()
+	}
+	return u.tctx
+}
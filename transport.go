@@ -0,0 +1,67 @@
+package mpic
+
+import (
+	"errors"
+	"time"
+
+	"github.com/richardnwinder/mpic/transport"
+)
+
+// Transport is the USB access used by Device, see package transport
+type Transport = transport.Transport
+
+// Simulator is an in-memory Transport, see package transport
+type Simulator = transport.Simulator
+
+// ErrSimTimeout is returned by Simulator for IN transfers with no data pending
+var ErrSimTimeout = transport.ErrSimTimeout
+
+// NewSimulator function returns a Simulator reporting version iver.irls
+func NewSimulator(iver int, irls int) *Simulator {
+	return transport.NewSimulator(iver, irls)
+}
+
+// ErrNoReconnect is returned by Reconnect on devices opened with OpenTransport
+var ErrNoReconnect = errors.New("Reconnect not supported by transport")
+
+// ErrDeviceGone is returned after a failed Reconnect or Close, until a
+// Reconnect succeeds
+var ErrDeviceGone = errors.New("Device is not connected")
+
+// OpenTransport function connects mpic device over an already opened
+// transport, for example a Simulator. The Device owns t, it is closed
+// when an option fails. The MPIC_* environment overrides only apply to
+// devices opened with Open, OpenSerial or OpenDriver.
+func OpenTransport(t Transport, opts ...Option) (*Device, error) {
+	return openTransport(t, opts, nil)
+}
+
+// openTransport is OpenTransport applying envOpts after opts
+func openTransport(t Transport, opts []Option, envOpts []Option) (*Device, error) {
+	/* EP2 buffers ob/ib are sized by Negotiate once the version is known */
+	mpic := &Device{
+		dev:   t,
+		drv:   mp4xDriver{},
+		epIn:  ep1in,
+		epOut: ep1out,
+		ocb:   iobuf{cnt: 0, buf: make([]byte, maxBufSize)},
+		icb:   iobuf{cnt: 0, buf: make([]byte, maxBufSize)},
+
+		opened: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(mpic)
+	}
+	/* environment overrides win over options */
+	for _, opt := range envOpts {
+		opt(mpic)
+	}
+	if mpic.optErr != nil {
+		t.Close()
+		return nil, mpic.optErr
+	}
+	for _, f := range mpic.hooks.open {
+		f(mpic)
+	}
+	return mpic, nil
+}
@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/richardnwinder/mpic/protocol"
+)
+
+// ErrSimTimeout is returned by Simulator for IN transfers with no data pending
+var ErrSimTimeout = errors.New("Simulator IN timeout")
+
+// Simulator is an in-memory Transport emulating the EP1 command protocol
+// of an mp4x device: every IN command answers INSYNC (0xff) followed by
+// its response packet. It is meant for tests and benchmarks.
+type Simulator struct {
+	mu      sync.Mutex
+	iver    byte
+	irls    byte
+	pending [][]byte /* IN packets queued for EP1 */
+	closed  bool
+
+	// Handler, if set, answers IN commands other than 0x93 (version).
+	// Returning nil sends only INSYNC and an empty packet.
+	Handler func(cmd byte, data []byte) []byte
+}
+
+// NewSimulator function returns a Simulator reporting version iver.irls
+func NewSimulator(iver int, irls int) *Simulator {
+	return &Simulator{iver: byte(iver), irls: byte(irls)}
+}
+
+// Close function marks the simulator closed
+func (s *Simulator) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
+
+// ClaimInterface function always succeeds
+func (s *Simulator) ClaimInterface(n uint32) error { return nil }
+
+// ReleaseInterface function always succeeds
+func (s *Simulator) ReleaseInterface(n uint32) error { return nil }
+
+// BulkTransfer function executes OUT commands on EP1 and returns queued
+// IN packets on EP1
+func (s *Simulator) BulkTransfer(endpoint uint32, length uint32, timeout uint32, data []byte) (int, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, nil, errors.New("Simulator closed")
+	}
+	switch endpoint {
+	case protocol.EP1Out:
+		if int(length) > len(data) {
+			return 0, nil, errors.New("Simulator bad command")
+		}
+		_, cmd, cdata, err := protocol.DecodeCommand(data[:length])
+		if err != nil {
+			return 0, nil, errors.New("Simulator bad command")
+		}
+		if protocol.IsIn(cmd) {
+			s.pending = append(s.pending, []byte{protocol.Insync}, s.answer(cmd, cdata))
+		}
+		return int(length), data[:length], nil
+	case protocol.EP1In:
+		if len(s.pending) == 0 {
+			return 0, nil, ErrSimTimeout
+		}
+		p := s.pending[0]
+		s.pending = s.pending[1:]
+		if len(p) > int(length) {
+			return 0, nil, errors.New("Simulator overflow")
+		}
+		n := copy(data, p)
+		return n, data[:n], nil
+	}
+	return 0, nil, errors.New("Simulator bad endpoint")
+}
+
+func (s *Simulator) answer(cmd byte, data []byte) []byte {
+	if cmd == protocol.CmdVersion {
+		return []byte{s.iver, s.irls}
+	}
+	if s.Handler != nil {
+		return s.Handler(cmd, data)
+	}
+	return nil
+}
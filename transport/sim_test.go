@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/richardnwinder/mpic/protocol"
+)
+
+func command(t *testing.T, s *Simulator, cmd byte, data []byte) {
+	buf := make([]byte, protocol.MaxPacketSize)
+	n, err := protocol.EncodeCommand(buf, protocol.DestMp4x, cmd, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _, err := s.BulkTransfer(protocol.EP1Out, uint32(n), 100, buf); err != nil || m != n {
+		t.Fatalf("OUT %d: %d %v", n, m, err)
+	}
+}
+
+func in(s *Simulator, length uint32) ([]byte, error) {
+	buf := make([]byte, protocol.MaxPacketSize)
+	_, data, err := s.BulkTransfer(protocol.EP1In, length, 100, buf)
+	return data, err
+}
+
+func TestSimulatorVersion(t *testing.T) {
+	s := NewSimulator(2, 1)
+	command(t, s, protocol.CmdVersion, nil)
+	sync, err := in(s, 1)
+	if err != nil || protocol.CheckInsync(len(sync), sync) != nil {
+		t.Fatalf("INSYNC % x %v", sync, err)
+	}
+	data, err := in(s, protocol.MaxPacketSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, r, err := protocol.ParseVersion(data); v != 2 || r != 1 || err != nil {
+		t.Fatalf("version %d.%d %v", v, r, err)
+	}
+	if _, err := in(s, protocol.MaxPacketSize); err != ErrSimTimeout {
+		t.Fatalf("empty EP1 IN: %v", err)
+	}
+}
+
+func TestSimulatorHandler(t *testing.T) {
+	s := NewSimulator(2, 1)
+	var got []byte
+	s.Handler = func(cmd byte, data []byte) []byte {
+		got = append([]byte{cmd}, data...)
+		return []byte{9}
+	}
+	command(t, s, 0x13, []byte{1}) /* OUT command, no answer */
+	if got != nil {
+		t.Fatal("handler called for an OUT command")
+	}
+	command(t, s, 0xa0, []byte{1, 2})
+	if !bytes.Equal(got, []byte{0xa0, 1, 2}) {
+		t.Fatalf("handler got % x", got)
+	}
+	in(s, 1)
+	if data, err := in(s, protocol.MaxPacketSize); err != nil || !bytes.Equal(data, []byte{9}) {
+		t.Fatalf("answer % x %v", data, err)
+	}
+}
+
+func TestSimulatorErrors(t *testing.T) {
+	s := NewSimulator(2, 1)
+	if _, _, err := s.BulkTransfer(protocol.EP1Out, 3, 100, []byte{4, 0x93, 5}); err == nil {
+		t.Error("bad frame accepted")
+	}
+	command(t, s, protocol.CmdVersion, nil)
+	in(s, 1)
+	if _, err := in(s, 1); err == nil {
+		t.Error("overflow accepted")
+	}
+	s.Close()
+	if _, err := in(s, 1); err == nil {
+		t.Error("closed simulator answered")
+	}
+}
@@ -0,0 +1,17 @@
+// Package transport provides the USB access used by package mpic: the
+// Transport interface, libusb devices and an in-memory Simulator of the
+// mp4x command protocol for testing without hardware.
+package transport
+
+import "errors"
+
+// Transport is the USB access used by mpic.Device, *usb.Device implements it
+type Transport interface {
+	Close()
+	ClaimInterface(n uint32) error
+	ReleaseInterface(n uint32) error
+	BulkTransfer(endpoint uint32, length uint32, timeout uint32, data []byte) (int, []byte, error)
+}
+
+// ErrNotSupported is returned by functions not available on this platform
+var ErrNotSupported = errors.New("Not supported on this platform")
@@ -0,0 +1,19 @@
+//go:build !(js && wasm)
+
+package transport
+
+import (
+	"github.com/richardnwinder/mpic/protocol"
+	"github.com/richardnwinder/usb"
+)
+
+var _ Transport = (*usb.Device)(nil)
+
+// OpenUSB function opens the mpic device by VID/PID with libusb
+func OpenUSB() (Transport, error) {
+	device, err := usb.OpenVidPid(protocol.VID, protocol.PID)
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
@@ -0,0 +1,8 @@
+//go:build js && wasm
+
+package transport
+
+// OpenUSB function is not supported in the browser, use package webusb
+func OpenUSB() (Transport, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,51 @@
+package mpic
+
+import (
+	"context"
+	"fmt"
+)
+
+// EventType tells whether a device was attached or removed
+type EventType int
+
+// Event types
+const (
+	DeviceAdded EventType = iota + 1
+	DeviceRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case DeviceAdded:
+		return "added"
+	case DeviceRemoved:
+		return "removed"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is one hotplug event delivered by Watch
+type Event struct {
+	Type   EventType
+	Device DeviceInfo /* Serial and strings are only set for DeviceAdded */
+}
+
+// Watch function returns a channel of hotplug events for mpic devices.
+// On Linux the events come from udev over netlink, after udev applied its
+// rules, so an added device can be opened right away. The channel is
+// closed when ctx ends.
+func Watch(ctx context.Context) (<-chan Event, error) {
+	return watchDevices(ctx)
+}
+
+// UdevRule function returns a udev rule giving group access with mode to
+// mpic devices, for example UdevRule("0660", "plugdev") to be saved as
+// /etc/udev/rules.d/99-mpic.rules
+func UdevRule(mode string, group string) string {
+	rule := fmt.Sprintf(`SUBSYSTEM=="usb", ATTR{idVendor}=="%04x", ATTR{idProduct}=="%04x", MODE="%s"`,
+		mp42Vid, mp42Pid, mode)
+	if group != "" {
+		rule += fmt.Sprintf(`, GROUP="%s"`, group)
+	}
+	return rule + "\n"
+}
@@ -0,0 +1,110 @@
+//go:build linux
+
+package mpic
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const udevMonitorGroup = 2 /* netlink group of events re-broadcast by udev */
+
+func watchDevices(ctx context.Context) (<-chan Event, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: udevMonitorGroup}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "uevent") /* runtime poller, Close unblocks Read */
+
+	ch := make(chan Event)
+	done := make(chan struct{}) /* closed when the reader stops */
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		f.Close()
+	}()
+	go func() {
+		defer close(ch)
+		defer close(done)
+		buf := make([]byte, 16384)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			ev, ok := parseUevent(buf[:n])
+			if !ok {
+				continue
+			}
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// parseUevent decodes a udev monitor message and keeps mpic usb_device events
+func parseUevent(msg []byte) (Event, bool) {
+	/* udev header: "libudev\0", magic 0xfeedcafe (big endian), header size,
+	 * properties offset, properties length (native endian) */
+	if len(msg) < 24 || !bytes.HasPrefix(msg, []byte("libudev\x00")) ||
+		binary.BigEndian.Uint32(msg[8:]) != 0xfeedcafe {
+		return Event{}, false
+	}
+	off := binary.NativeEndian.Uint32(msg[16:])
+	size := binary.NativeEndian.Uint32(msg[20:])
+	if uint64(off)+uint64(size) > uint64(len(msg)) {
+		return Event{}, false
+	}
+	props := make(map[string]string)
+	for _, kv := range bytes.Split(msg[off:off+size], []byte{0}) {
+		if k, v, ok := strings.Cut(string(kv), "="); ok {
+			props[k] = v
+		}
+	}
+	if props["SUBSYSTEM"] != "usb" || props["DEVTYPE"] != "usb_device" ||
+		props["PRODUCT"] == "" || !strings.HasPrefix(props["PRODUCT"], fmt.Sprintf("%x/%x/", mp42Vid, mp42Pid)) {
+		return Event{}, false
+	}
+	var ev Event
+	switch props["ACTION"] {
+	case "add":
+		ev.Type = DeviceAdded
+	case "remove":
+		ev.Type = DeviceRemoved
+	default:
+		return Event{}, false
+	}
+	ev.Device.Path = path.Base(props["DEVPATH"])
+	ev.Device.Bus, _ = strconv.Atoi(props["BUSNUM"])
+	ev.Device.Address, _ = strconv.Atoi(props["DEVNUM"])
+	if ev.Type == DeviceAdded {
+		dir := filepath.Join("/sys", props["DEVPATH"])
+		ev.Device.Serial = sysfsString(dir, "serial")
+		ev.Device.Manufacturer = sysfsString(dir, "manufacturer")
+		ev.Device.Product = sysfsString(dir, "product")
+		ev.Device.Speed = sysfsString(dir, "speed")
+	}
+	return ev, true
+}
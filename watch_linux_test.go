@@ -0,0 +1,67 @@
+//go:build linux
+
+package mpic
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+/* udev monitor message: 40 byte libudev header followed by the properties */
+func uevent(props ...string) []byte {
+	body := []byte(strings.Join(props, "\x00") + "\x00")
+	msg := make([]byte, 40, 40+len(body))
+	copy(msg, "libudev\x00")
+	binary.BigEndian.PutUint32(msg[8:], 0xfeedcafe)
+	binary.NativeEndian.PutUint32(msg[12:], 40)
+	binary.NativeEndian.PutUint32(msg[16:], 40)
+	binary.NativeEndian.PutUint32(msg[20:], uint32(len(body)))
+	return append(msg, body...)
+}
+
+/* captured from udevadm monitor --udev --property, MP42 on bus 1 port 2 */
+var ueventProps = []string{
+	"DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-2",
+	"SUBSYSTEM=usb",
+	"DEVNAME=/dev/bus/usb/001/005",
+	"DEVTYPE=usb_device",
+	"PRODUCT=4d8/fca7/100",
+	"TYPE=0/0/0",
+	"BUSNUM=001",
+	"DEVNUM=005",
+	"SEQNUM=4242",
+	"MAJOR=189",
+	"MINOR=4",
+}
+
+func TestParseUevent(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		msg   []byte
+		ok    bool
+		event EventType
+	}{
+		{"add", uevent(append([]string{"ACTION=add"}, ueventProps...)...), true, DeviceAdded},
+		{"remove", uevent(append([]string{"ACTION=remove"}, ueventProps...)...), true, DeviceRemoved},
+		{"bind", uevent(append([]string{"ACTION=bind"}, ueventProps...)...), false, 0},
+		{"other product", uevent("ACTION=add", "SUBSYSTEM=usb", "DEVTYPE=usb_device", "PRODUCT=46d/c52b/1211",
+			"DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-3"), false, 0},
+		{"interface", uevent("ACTION=add", "SUBSYSTEM=usb", "DEVTYPE=usb_interface", "PRODUCT=4d8/fca7/100",
+			"DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0"), false, 0},
+		{"kernel message", []byte("add@/devices/pci0000:00/0000:00:14.0/usb1/1-2\x00ACTION=add\x00"), false, 0},
+		{"truncated", uevent(append([]string{"ACTION=add"}, ueventProps...)...)[:60], false, 0},
+	} {
+		ev, ok := parseUevent(tc.msg)
+		if ok != tc.ok {
+			t.Errorf("%s: ok = %v", tc.name, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if ev.Type != tc.event || ev.Device.Path != "1-2" || ev.Device.Bus != 1 || ev.Device.Address != 5 {
+			t.Errorf("%s: %+v", tc.name, ev)
+		}
+	}
+}
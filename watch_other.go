@@ -0,0 +1,9 @@
+//go:build !linux
+
+package mpic
+
+import "context"
+
+func watchDevices(ctx context.Context) (<-chan Event, error) {
+	return nil, ErrNotSupported
+}
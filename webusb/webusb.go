@@ -0,0 +1,207 @@
+//go:build js && wasm
+
+// Package webusb implements the mpic Transport over WebUSB, so browser
+// based tools built with GOOS=js GOARCH=wasm can drive the device with the
+// same protocol code:
+//
+//	t, err := webusb.Request() /* from a goroutine started by a click handler */
+//	dev, err := mpic.OpenTransport(t)
+//
+// All functions wait for JavaScript promises and must not be called on the
+// goroutine running a JavaScript callback, start a new goroutine instead.
+package webusb
+
+import (
+	"errors"
+	"syscall/js"
+	"time"
+
+	"github.com/richardnwinder/mpic/protocol"
+	"github.com/richardnwinder/mpic/transport"
+)
+
+// ErrNoWebUSB is returned when the browser has no navigator.usb
+var ErrNoWebUSB = errors.New("WebUSB not available")
+
+// ErrTimeout is returned when a transfer did not complete in time. WebUSB
+// transfers can not be cancelled: a timed out IN transfer stays pending
+// and the next IN BulkTransfer on its endpoint returns its data instead
+// of starting a new transfer, so no packet is lost.
+var ErrTimeout = errors.New("WebUSB transfer timeout")
+
+// Transport is an opened WebUSB device, it implements transport.Transport
+type Transport struct {
+	dev     js.Value
+	pending map[uint32]<-chan result /* timed out transferIn by endpoint */
+}
+
+type result struct {
+	v   js.Value
+	err error
+}
+
+var _ transport.Transport = (*Transport)(nil)
+
+// await waits for promise p and returns its value or rejection
+func await(p js.Value, timeout time.Duration) (js.Value, error) {
+	r, ok := wait(settle(p), timeout)
+	if !ok {
+		return js.Undefined(), ErrTimeout
+	}
+	return r.v, r.err
+}
+
+// wait waits for ch, ok is false on timeout
+func wait(ch <-chan result, timeout time.Duration) (r result, ok bool) {
+	if timeout <= 0 {
+		return <-ch, true
+	}
+	select {
+	case r = <-ch:
+		return r, true
+	case <-time.After(timeout):
+		return r, false
+	}
+}
+
+// settle returns a channel receiving the value or rejection of promise p
+func settle(p js.Value) <-chan result {
+	ch := make(chan result, 1)
+	var then, catch js.Func
+	then = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		ch <- result{v: args[0]}
+		then.Release()
+		catch.Release()
+		return nil
+	})
+	catch = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		ch <- result{err: js.Error{Value: args[0]}}
+		then.Release()
+		catch.Release()
+		return nil
+	})
+	p.Call("then", then).Call("catch", catch)
+	return ch
+}
+
+func usb() (js.Value, error) {
+	u := js.Global().Get("navigator").Get("usb")
+	if u.IsUndefined() {
+		return js.Undefined(), ErrNoWebUSB
+	}
+	return u, nil
+}
+
+// Request function shows the browser device chooser filtered on mpic
+// devices and opens the selected one. Browsers only allow it during a
+// user gesture.
+func Request() (*Transport, error) {
+	u, err := usb()
+	if err != nil {
+		return nil, err
+	}
+	filter := map[string]interface{}{"vendorId": protocol.VID, "productId": protocol.PID}
+	opts := map[string]interface{}{"filters": []interface{}{filter}}
+	dev, err := await(u.Call("requestDevice", opts), 0)
+	if err != nil {
+		return nil, err
+	}
+	return open(dev)
+}
+
+// Devices function opens the mpic devices the user already granted access to
+func Devices() ([]*Transport, error) {
+	u, err := usb()
+	if err != nil {
+		return nil, err
+	}
+	list, err := await(u.Call("getDevices"), 0)
+	if err != nil {
+		return nil, err
+	}
+	var ts []*Transport
+	for i := 0; i < list.Length(); i++ {
+		d := list.Index(i)
+		if d.Get("vendorId").Int() != protocol.VID || d.Get("productId").Int() != protocol.PID {
+			continue
+		}
+		t, err := open(d)
+		if err != nil {
+			return ts, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+func open(dev js.Value) (*Transport, error) {
+	if _, err := await(dev.Call("open"), 0); err != nil {
+		return nil, err
+	}
+	if dev.Get("configuration").IsNull() {
+		if _, err := await(dev.Call("selectConfiguration", 1), 0); err != nil {
+			return nil, err
+		}
+	}
+	return &Transport{dev: dev, pending: make(map[uint32]<-chan result)}, nil
+}
+
+// Close function closes the device
+func (t *Transport) Close() {
+	await(t.dev.Call("close"), 0)
+}
+
+// ClaimInterface function claims interface n
+func (t *Transport) ClaimInterface(n uint32) error {
+	_, err := await(t.dev.Call("claimInterface", n), 0)
+	return err
+}
+
+// ReleaseInterface function releases interface n
+func (t *Transport) ReleaseInterface(n uint32) error {
+	_, err := await(t.dev.Call("releaseInterface", n), 0)
+	return err
+}
+
+// BulkTransfer function runs one bulk transfer, endpoint bit 7 selects IN
+func (t *Transport) BulkTransfer(endpoint uint32, length uint32, timeout uint32, data []byte) (int, []byte, error) {
+	num := endpoint & 0x0f
+	tmo := time.Duration(timeout) * time.Millisecond
+	if (endpoint & 0x80) == 0 {
+		if int(length) > len(data) {
+			return 0, nil, errors.New("WebUSB OUT length exceeds buffer")
+		}
+		buf := js.Global().Get("Uint8Array").New(int(length))
+		js.CopyBytesToJS(buf, data[:length])
+		res, err := await(t.dev.Call("transferOut", num, buf), tmo)
+		if err != nil {
+			return 0, nil, err
+		}
+		if s := res.Get("status").String(); s != "ok" {
+			return 0, nil, errors.New("WebUSB transferOut " + s)
+		}
+		n := res.Get("bytesWritten").Int()
+		return n, data[:n], nil
+	}
+	ch, ok := t.pending[num]
+	if !ok {
+		ch = settle(t.dev.Call("transferIn", num, length))
+	}
+	r, ok := wait(ch, tmo)
+	if !ok {
+		t.pending[num] = ch
+		return 0, nil, ErrTimeout
+	}
+	delete(t.pending, num)
+	res, err := r.v, r.err
+	if err != nil {
+		return 0, nil, err
+	}
+	if s := res.Get("status").String(); s != "ok" {
+		return 0, nil, errors.New("WebUSB transferIn " + s)
+	}
+	dv := res.Get("data")
+	buf := js.Global().Get("Uint8Array").New(dv.Get("buffer"), dv.Get("byteOffset"), dv.Get("byteLength"))
+	n := js.CopyBytesToGo(data, buf)
+	return n, data[:n], nil
+}